@@ -1,11 +1,260 @@
 package gostorage
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/viper"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
 )
 
 type CredentialsHolder struct {
 	AwsCredentials    *aws.Credentials
 	GoogleCredentials *google.Credentials
+	// AwsCredentialsProvider is used instead of AwsCredentials if set, e.g. for credentials from the AWS default chain
+	AwsCredentialsProvider aws.CredentialsProvider
+}
+
+type CredentialsSource string
+
+const (
+	// CredentialsSourceNone no credentials are loaded, the provider can not be used
+	CredentialsSourceNone CredentialsSource = ""
+	// CredentialsSourceFile credentials are read from FilePath (aws-credentials.yaml format for AWS, service account JSON for Google)
+	CredentialsSourceFile CredentialsSource = "file"
+	// CredentialsSourceEnv credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN for AWS
+	// and from the file referenced by GOOGLE_APPLICATION_CREDENTIALS for Google
+	CredentialsSourceEnv CredentialsSource = "env"
+	// CredentialsSourceProfile credentials of Profile are read from the AWS shared config files (AWS only)
+	CredentialsSourceProfile CredentialsSource = "profile"
+	// CredentialsSourceDefault uses the AWS default credential chain (incl. instance/role credentials) or
+	// the Google Application Default Credentials respectively
+	CredentialsSourceDefault CredentialsSource = "default"
+)
+
+type ProviderCredentialsOptions struct {
+	Source   CredentialsSource
+	FilePath string
+	Profile  string
+	// Region of the STS endpoint used by AssumeRole and WebIdentity (AWS only), defaults to the region of the AWS shared
+	// config or environment (AWS_REGION) and then to DefaultAWSRegion
+	Region string
+	// AssumeRole assumes the role with the credentials loaded from Source, or with the role credentials of WebIdentity
+	// if it is set (AWS only)
+	AssumeRole *AssumeRoleOptions
+	// WebIdentity exchanges a web identity token for role credentials, Source is ignored (AWS only)
+	WebIdentity *WebIdentityOptions
+	// Impersonate impersonates a service account with the credentials loaded from Source (Google only)
+	Impersonate *ImpersonationOptions
+}
+
+type AssumeRoleOptions struct {
+	RoleArn     string
+	ExternalId  string
+	SessionName string
+	// Duration of the role session, defaults to 15 minutes
+	Duration time.Duration
+}
+
+type WebIdentityOptions struct {
+	RoleArn     string
+	SessionName string
+	// TokenFile path of the file containing the web identity token, it is re-read on every refresh
+	TokenFile string
+}
+
+type ImpersonationOptions struct {
+	TargetServiceAccount string
+	// Delegates chain of service accounts used to impersonate TargetServiceAccount
+	Delegates []string
+	// Lifetime of the access token, defaults to 1 hour
+	Lifetime time.Duration
+}
+
+// CredentialsOptions configures per provider where the credentials are loaded from,
+// providers with CredentialsSourceNone do not require any credentials
+type CredentialsOptions struct {
+	AWS    ProviderCredentialsOptions
+	Google ProviderCredentialsOptions
+}
+
+// LoadCredentials resolves the credentials of all providers according to options
+func LoadCredentials(options CredentialsOptions) CredentialsHolder {
+	credentialsHolder := CredentialsHolder{}
+	switch options.AWS.Source {
+	case CredentialsSourceNone:
+	case CredentialsSourceFile:
+		credentialsHolder.AwsCredentials = loadAWSCredentialsFromFile(options.AWS.FilePath)
+	case CredentialsSourceEnv:
+		credentialsHolder.AwsCredentials = loadAWSCredentialsFromEnv()
+	case CredentialsSourceProfile:
+		credentialsHolder.AwsCredentialsProvider = loadAWSCredentialsProvider(config.WithSharedConfigProfile(options.AWS.Profile))
+	case CredentialsSourceDefault:
+		credentialsHolder.AwsCredentialsProvider = loadAWSCredentialsProvider()
+	default:
+		fail(nil, fmt.Sprintf("unknown credentials source %v for AWS", options.AWS.Source))
+	}
+	if options.AWS.WebIdentity != nil {
+		credentialsHolder.AwsCredentials = nil
+		credentialsHolder.AwsCredentialsProvider = assumeRoleWithWebIdentity(stsRegion(options.AWS), *options.AWS.WebIdentity)
+	}
+	if options.AWS.AssumeRole != nil {
+		credentialsHolder.AwsCredentialsProvider = assumeRole(credentialsHolder.awsCredentialsProvider(), stsRegion(options.AWS), *options.AWS.AssumeRole)
+	}
+
+	switch options.Google.Source {
+	case CredentialsSourceNone:
+	case CredentialsSourceFile:
+		credentialsHolder.GoogleCredentials = loadGoogleCredentialsFromFile(options.Google.FilePath)
+	case CredentialsSourceEnv:
+		credentialsFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		if credentialsFile == "" {
+			fail(nil, "unable to load Google credentials, GOOGLE_APPLICATION_CREDENTIALS is not set")
+		}
+		credentialsHolder.GoogleCredentials = loadGoogleCredentialsFromFile(credentialsFile)
+	case CredentialsSourceDefault:
+		googleCredentials, err := google.FindDefaultCredentials(context.Background(), GoogleStorageScope)
+		checkErr(err, fmt.Sprintf("unable to find Google application default credentials, Error: %v", err))
+		credentialsHolder.GoogleCredentials = googleCredentials
+	default:
+		fail(nil, fmt.Sprintf("unknown credentials source %v for Google", options.Google.Source))
+	}
+	if options.Google.Impersonate != nil {
+		credentialsHolder.GoogleCredentials = impersonateServiceAccount(credentialsHolder.GoogleCredentials, *options.Google.Impersonate)
+	}
+	return credentialsHolder
+}
+
+// awsCredentialsProvider returns the configured AWS credentials provider or nil if there are no AWS credentials.
+// Providers are wrapped in a cache which refreshes the credentials before they expire.
+func (c CredentialsHolder) awsCredentialsProvider() aws.CredentialsProvider {
+	if c.AwsCredentialsProvider != nil {
+		return refreshingCredentialsProvider(c.AwsCredentialsProvider)
+	}
+	if c.AwsCredentials != nil {
+		return credentials.StaticCredentialsProvider{Value: *c.AwsCredentials}
+	}
+	return nil
+}
+
+// refreshingCredentialsProvider wraps provider in a cache refreshing credentials CredentialsRefreshWindow before they expire.
+// Existing caches are returned as they are, the providers of the AWS SDK config are cached by loadAWSCredentialsProvider.
+func refreshingCredentialsProvider(provider aws.CredentialsProvider) aws.CredentialsProvider {
+	if cache, ok := provider.(*aws.CredentialsCache); ok {
+		return cache
+	}
+	return aws.NewCredentialsCache(provider, func(options *aws.CredentialsCacheOptions) {
+		options.ExpiryWindow = CredentialsRefreshWindow
+	})
+}
+
+// stsRegion returns the region of the STS endpoint used to assume roles
+func stsRegion(options ProviderCredentialsOptions) string {
+	if options.Region != "" {
+		return options.Region
+	}
+	var optFns []func(*config.LoadOptions) error
+	if options.Source == CredentialsSourceProfile {
+		optFns = append(optFns, config.WithSharedConfigProfile(options.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), optFns...)
+	checkErr(err, fmt.Sprintf("unable to load AWS SDK config, Error: %v", err))
+	if cfg.Region != "" {
+		return cfg.Region
+	}
+	return DefaultAWSRegion
+}
+
+func assumeRole(baseProvider aws.CredentialsProvider, region string, options AssumeRoleOptions) aws.CredentialsProvider {
+	if baseProvider == nil {
+		fail(nil, "unable to assume role, no AWS credentials configured")
+	}
+	stsClient := sts.NewFromConfig(aws.Config{Region: region, Credentials: baseProvider})
+	return refreshingCredentialsProvider(stscreds.NewAssumeRoleProvider(stsClient, options.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		if options.ExternalId != "" {
+			o.ExternalID = aws.String(options.ExternalId)
+		}
+		if options.SessionName != "" {
+			o.RoleSessionName = options.SessionName
+		}
+		if options.Duration != 0 {
+			o.Duration = options.Duration
+		}
+	}))
+}
+
+func assumeRoleWithWebIdentity(region string, options WebIdentityOptions) aws.CredentialsProvider {
+	stsClient := sts.NewFromConfig(aws.Config{Region: region})
+	return refreshingCredentialsProvider(stscreds.NewWebIdentityRoleProvider(stsClient, options.RoleArn, stscreds.IdentityTokenFile(options.TokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = fmt.Sprintf("gostorage-%v", time.Now().UnixNano())
+		if options.SessionName != "" {
+			o.RoleSessionName = options.SessionName
+		}
+	}))
+}
+
+// impersonateServiceAccount returns credentials of the target service account, the access tokens are refreshed automatically before they expire
+func impersonateServiceAccount(baseCredentials *google.Credentials, options ImpersonationOptions) *google.Credentials {
+	if baseCredentials == nil {
+		fail(nil, "unable to impersonate service account, no Google credentials configured")
+	}
+	tokenSource, err := impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+		TargetPrincipal: options.TargetServiceAccount,
+		Scopes:          []string{GoogleStorageScope},
+		Delegates:       options.Delegates,
+		Lifetime:        options.Lifetime,
+	}, option.WithCredentials(baseCredentials))
+	checkErr(err, fmt.Sprintf("unable to impersonate service account %v, Error: %v", options.TargetServiceAccount, err))
+	return &google.Credentials{ProjectID: baseCredentials.ProjectID, TokenSource: tokenSource}
+}
+
+func loadAWSCredentialsFromFile(filePath string) *aws.Credentials {
+	credentialsConfig := viper.New()
+	credentialsConfig.SetConfigFile(filePath)
+	credentialsConfig.SetConfigType("yaml")
+	err := credentialsConfig.ReadInConfig()
+	checkErr(err, fmt.Sprintf("unable to read credentials file {%v}, Error: %v", filePath, err))
+	return &aws.Credentials{
+		AccessKeyID:     credentialsConfig.GetString(AWSAccessKey),
+		SecretAccessKey: credentialsConfig.GetString(AWSSecretAccessKey),
+		SessionToken:    credentialsConfig.GetString(AWSSessionTokenKey),
+	}
+}
+
+func loadAWSCredentialsFromEnv() *aws.Credentials {
+	awsCredentials := &aws.Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if awsCredentials.AccessKeyID == "" || awsCredentials.SecretAccessKey == "" {
+		fail(nil, "unable to load AWS credentials, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return awsCredentials
+}
+
+// loadAWSCredentialsProvider returns the credentials provider of the AWS SDK config, refreshing credentials
+// CredentialsRefreshWindow before they expire
+func loadAWSCredentialsProvider(optFns ...func(*config.LoadOptions) error) aws.CredentialsProvider {
+	optFns = append(optFns, config.WithCredentialsCacheOptions(func(options *aws.CredentialsCacheOptions) {
+		options.ExpiryWindow = CredentialsRefreshWindow
+	}))
+	cfg, err := config.LoadDefaultConfig(context.Background(), optFns...)
+	checkErr(err, fmt.Sprintf("unable to load AWS SDK config, Error: %v", err))
+	return cfg.Credentials
+}
+
+func loadGoogleCredentialsFromFile(filePath string) *google.Credentials {
+	googleCredentials, err := google.CredentialsFromJSON(context.Background(), readFile(filePath), GoogleStorageScope)
+	checkErr(err, fmt.Sprintf("unable to parse Google credentials file {%v}, Error: %v", filePath, err))
+	return googleCredentials
 }
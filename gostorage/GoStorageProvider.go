@@ -3,16 +3,49 @@ package gostorage
 import "io"
 
 type Provider interface {
-	createBucket(bucketName string, region string)
+	createBucket(target GoStorageObject, options BucketOptions) CreateBucketResult
+	bucketStatus(target GoStorageObject) BucketStatus
+	listBuckets() []BucketInfo
 	deleteBucket(target GoStorageObject, deleteIfNotEmpty bool)
 
 	copyFileWithinProvider(source GoStorageObject, target GoStorageObject)
 	copyBucketWithinProvider(source GoStorageObject, target GoStorageObject)
+	canAccess(source GoStorageObject) bool
 
 	uploadFile(target GoStorageObject, sourceFile string)
 	downloadFile(source GoStorageObject, targetFile string)
 	downloadFileAsReader(source GoStorageObject) io.Reader
 	listFilesInBucket(source GoStorageObject) []string
+	listDirectory(source GoStorageObject) (keys []string, prefixes []string)
+	listObjects(source GoStorageObject) []ObjectInfo
 
 	deleteFile(target GoStorageObject)
+	deleteFiles(targets []GoStorageObject) []DeleteResult
+	statObject(source GoStorageObject) ObjectInfo
+	isModified(source GoStorageObject, cached ObjectInfo) bool
+	setObjectMetadata(target GoStorageObject)
+
+	setBucketVersioning(target GoStorageObject, enabled bool)
+	listObjectVersions(source GoStorageObject) []ObjectVersion
+	restoreObjectVersion(source GoStorageObject)
+
+	getLifecycleRules(target GoStorageObject) []LifecycleRule
+	setLifecycleRules(target GoStorageObject, rules []LifecycleRule)
+	deleteLifecycleRules(target GoStorageObject)
+
+	setObjectACL(target GoStorageObject, acl ACL)
+	setPublicAccessBlock(target GoStorageObject, blocked bool)
+	setUniformBucketLevelAccess(target GoStorageObject, enabled bool)
+	getBucketPolicy(target GoStorageObject) string
+	setBucketPolicy(target GoStorageObject, policy string)
+
+	setDefaultBucketEncryption(target GoStorageObject, encryption Encryption)
+
+	getObjectTags(target GoStorageObject) map[string]string
+	setObjectTags(target GoStorageObject, tags map[string]string)
+	deleteObjectTags(target GoStorageObject)
+	getBucketTags(target GoStorageObject) map[string]string
+	setBucketTags(target GoStorageObject, tags map[string]string)
+	deleteBucketTags(target GoStorageObject)
+	listFilesWithTags(source GoStorageObject, tags map[string]string) []string
 }
@@ -2,19 +2,62 @@ package gostorage
 
 import (
 	"fmt"
-	"os"
+	"time"
 )
 
 // GoStorageObject This type serves as an abstraction of a unit of storage (Local file, S3/Google Storage Object)
 type GoStorageObject struct {
 	Bucket        string
 	Key           string
+	VersionId     string
 	Region        string
 	IsLocal       bool
 	LocalFilePath string
 	ProviderType  ProviderType
+	ACL           ACL
+	Encryption    *Encryption
+	Metadata      map[string]string
+	// Tags are S3 object tags or Google custom metadata respectively, set on upload and copy
+	Tags map[string]string
+	// Account name of the account in Config.Accounts used to access the object, the default credentials are used if empty
+	Account string
 }
 
+// ObjectInfo holds the attributes of a stored object
+type ObjectInfo struct {
+	Key          string
+	VersionId    string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// BucketInfo holds the attributes of a bucket, Region is the AWS region or Google location respectively
+type BucketInfo struct {
+	Name         string
+	Region       string
+	CreationTime time.Time
+	ProviderType ProviderType
+}
+
+type CreateBucketResult string
+
+const (
+	BucketCreated       CreateBucketResult = "created"
+	BucketAlreadyExists CreateBucketResult = "already-exists"
+)
+
+type BucketStatus string
+
+const (
+	BucketNotFound   BucketStatus = "not-found"
+	BucketAccessible BucketStatus = "accessible"
+	// BucketInaccessible the bucket exists but can not be accessed with the credentials (e.g. it is owned by another
+	// account) or, on AWS, in the region of the storage object
+	BucketInaccessible BucketStatus = "inaccessible"
+)
+
 type ProviderType string
 
 const (
@@ -23,14 +66,31 @@ const (
 )
 
 func (receiver GoStorageObject) GetProvider(credentialsHolder CredentialsHolder) Provider {
+	return receiver.getProvider(Config{Credentials: credentialsHolder}, nil)
+}
+
+func (receiver GoStorageObject) getProvider(config Config, clients *clientCache) Provider {
+	if receiver.Account != "" {
+		account, ok := config.Accounts[receiver.Account]
+		if !ok {
+			fail(nil, fmt.Sprintf("unable to find account %v", receiver.Account))
+		}
+		config.Credentials = account.Credentials
+		if account.GoogleProjectId != "" {
+			config.GoogleProjectId = account.GoogleProjectId
+		}
+	}
+	if clients == nil {
+		clients = config.sharedClientCache()
+	}
+
 	switch receiver.ProviderType {
 	case ProviderGoogle:
-		return GoogleStorage{CredentialsHolder: credentialsHolder}
+		return GoogleStorage{CredentialsHolder: config.Credentials, config: config, clients: clients, account: receiver.Account}
 	case ProviderAWS:
-		return AWSStorage{CredentialsHolder: credentialsHolder}
+		return AWSStorage{CredentialsHolder: config.Credentials, config: config, clients: clients, account: receiver.Account}
 	default:
-		fmt.Fprintln(os.Stderr, "Error:", "unable to create the respective provider for provider type", receiver.ProviderType)
-		os.Exit(1)
+		fail(nil, fmt.Sprintf("unable to create the respective provider for provider type %v", receiver.ProviderType))
 		return nil
 	}
 }
@@ -0,0 +1,60 @@
+package gostorage
+
+// ACL common abstraction of the canned ACLs offered by both providers
+type ACL string
+
+const (
+	ACLPrivate           ACL = "private"
+	ACLPublicRead        ACL = "public-read"
+	ACLAuthenticatedRead ACL = "authenticated-read"
+)
+
+// googlePredefinedACL maps the ACL to the name of the respective predefined ACL on Google Storage
+func (acl ACL) googlePredefinedACL() string {
+	switch acl {
+	case ACLPrivate:
+		return "private"
+	case ACLPublicRead:
+		return "publicRead"
+	case ACLAuthenticatedRead:
+		return "authenticatedRead"
+	default:
+		return string(acl)
+	}
+}
+
+// SetObjectACL applies the canned ACL to an existing object, to set the ACL on upload or copy set GoStorageObject.ACL of the target
+func (s GoStorage) SetObjectACL(target GoStorageObject, acl ACL) {
+	s, span := s.startSpan("SetObjectACL", target)
+	defer span.End()
+	s.provider(target).setObjectACL(target, acl)
+}
+
+// SetPublicAccessBlock blocks (or allows) any public access to the bucket and its objects
+func (s GoStorage) SetPublicAccessBlock(bucket GoStorageObject, blocked bool) {
+	s, span := s.startSpan("SetPublicAccessBlock", bucket)
+	defer span.End()
+	s.provider(bucket).setPublicAccessBlock(bucket, blocked)
+}
+
+// SetUniformBucketLevelAccess disables (or enables) object ACLs, so access is only controlled by the bucket policy.
+// On AWS this is done by setting the object ownership to BucketOwnerEnforced.
+func (s GoStorage) SetUniformBucketLevelAccess(bucket GoStorageObject, enabled bool) {
+	s, span := s.startSpan("SetUniformBucketLevelAccess", bucket)
+	defer span.End()
+	s.provider(bucket).setUniformBucketLevelAccess(bucket, enabled)
+}
+
+// GetBucketPolicy returns the policy of the bucket as JSON document (AWS bucket policy or Google IAM policy)
+func (s GoStorage) GetBucketPolicy(bucket GoStorageObject) string {
+	s, span := s.startSpan("GetBucketPolicy", bucket)
+	defer span.End()
+	return s.provider(bucket).getBucketPolicy(bucket)
+}
+
+// SetBucketPolicy replaces the policy of the bucket with the given JSON document (AWS bucket policy or Google IAM policy)
+func (s GoStorage) SetBucketPolicy(bucket GoStorageObject, policy string) {
+	s, span := s.startSpan("SetBucketPolicy", bucket)
+	defer span.End()
+	s.provider(bucket).setBucketPolicy(bucket, policy)
+}
@@ -7,63 +7,168 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	aws_s3 "github.com/aws/aws-sdk-go-v2/service/s3"
 	types2 "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type AWSStorage struct {
 	CredentialsHolder CredentialsHolder
+
+	config  Config
+	clients *clientCache
+	account string
 }
 
-func (a AWSStorage) createBucket(bucketName string, region string) {
-	bucketInput := &aws_s3.CreateBucketInput{Bucket: &bucketName}
-	if region != "" && region != DefaultAWSRegion {
+// createBucket creates the bucket, settings which can not be applied on creation are applied afterwards and the bucket is
+// deleted again if this fails
+func (a AWSStorage) createBucket(target GoStorageObject, options BucketOptions) CreateBucketResult {
+	region := target.Region
+	if options.Location != "" {
+		region = options.Location
+	}
+	if region == "" {
+		region = a.config.awsRegion()
+	}
+	if options.StorageClass != "" {
+		fail(nil, fmt.Sprintf("unable to create bucket %v, AWS does not support default storage classes for buckets", target.Bucket))
+	}
+	switch a.bucketStatus(GoStorageObject{Bucket: target.Bucket, Region: region}) {
+	case BucketAccessible:
+		return BucketAlreadyExists
+	case BucketInaccessible:
+		fail(nil, fmt.Sprintf("unable to create bucket %v, it exists on AWS but is not accessible in region %v, it is "+
+			"probably owned by another account or located in another region", target.Bucket, region))
+	}
+
+	bucketInput := &aws_s3.CreateBucketInput{Bucket: &target.Bucket, ObjectLockEnabledForBucket: options.ObjectLock}
+	//us-east-1 is the only region which must not be specified as location constraint
+	if region != "us-east-1" {
 		bucketInput.CreateBucketConfiguration = &types2.CreateBucketConfiguration{LocationConstraint: types2.BucketLocationConstraint(region)}
 	}
-	_, err := a.getClientWithRegion(region).CreateBucket(context.Background(), bucketInput)
+	if options.UniformBucketLevelAccess {
+		bucketInput.ObjectOwnership = types2.ObjectOwnershipBucketOwnerEnforced
+	}
+	storageClient := a.getClientWithRegion(region)
+	_, err := storageClient.CreateBucket(context.Background(), bucketInput)
+	if hasAWSErrorCode(err, "BucketAlreadyOwnedByYou") {
+		return BucketAlreadyExists
+	}
 	checkErr(err, fmt.Sprintf("unable to create bucket on AWS, Error %v", err))
+
+	if options.Versioning && !options.ObjectLock {
+		_, err = storageClient.PutBucketVersioning(context.Background(), &aws_s3.PutBucketVersioningInput{
+			Bucket:                  &target.Bucket,
+			VersioningConfiguration: &types2.VersioningConfiguration{Status: types2.BucketVersioningStatusEnabled},
+		})
+		a.rollbackBucketCreation(storageClient, target.Bucket, err)
+	}
+	if len(options.Labels) > 0 {
+		_, err = storageClient.PutBucketTagging(context.Background(), &aws_s3.PutBucketTaggingInput{Bucket: &target.Bucket, Tagging: &types2.Tagging{TagSet: awsTagSet(options.Labels)}})
+		a.rollbackBucketCreation(storageClient, target.Bucket, err)
+	}
+	return BucketCreated
 }
 
-func (a AWSStorage) deleteBucket(target GoStorageObject, deleteIfNotEmpty bool) {
-	filesInBucket := a.listFilesInBucket(target)
-	if len(filesInBucket) > 0 && !deleteIfNotEmpty {
-		fmt.Printf("bucket %v is not empty and should not be deleted, if you want to delete a bucket with its contents set deleteIfNotEmpty=true", target.Bucket)
+// rollbackBucketCreation deletes the newly created bucket if err is set and exits
+func (a AWSStorage) rollbackBucketCreation(storageClient *aws_s3.Client, bucketName string, err error) {
+	if err == nil {
 		return
 	}
+	_, deleteErr := storageClient.DeleteBucket(context.Background(), &aws_s3.DeleteBucketInput{Bucket: &bucketName})
+	checkErr(deleteErr, fmt.Sprintf("unable to configure bucket %v on AWS and to delete it again, Error: %v, %v", bucketName, err, deleteErr))
+	checkErr(err, fmt.Sprintf("unable to configure bucket %v on AWS, the bucket was deleted again, Error: %v", bucketName, err))
+}
+
+// bucketStatus checks whether the bucket exists and is accessible, buckets which exist but are owned by another account
+// or are located in another region than target.Region are inaccessible
+func (a AWSStorage) bucketStatus(target GoStorageObject) BucketStatus {
+	_, err := a.getClientWithRegion(target.Region).HeadBucket(context.Background(), &aws_s3.HeadBucketInput{Bucket: &target.Bucket})
+	if err == nil {
+		return BucketAccessible
+	}
+	switch httpStatusCode(err) {
+	case http.StatusNotFound:
+		return BucketNotFound
+	case http.StatusForbidden, http.StatusMovedPermanently:
+		return BucketInaccessible
+	}
+	checkErr(err, fmt.Sprintf("unable to check whether bucket %v exists on AWS, Error: %v", target.Bucket, err))
+	return BucketNotFound
+}
 
-	for _, key := range filesInBucket {
-		a.deleteFile(GoStorageObject{Bucket: target.Bucket, Key: key, Region: target.Region})
+func (a AWSStorage) listBuckets() []BucketInfo {
+	var buckets []BucketInfo
+	storageClient := a.getClientWithRegion("")
+	listBucketsOutput, err := storageClient.ListBuckets(context.Background(), &aws_s3.ListBucketsInput{})
+	checkErr(err, fmt.Sprintf("unable to list buckets on AWS, Error: %v", err))
+	for _, b := range listBucketsOutput.Buckets {
+		locationOutput, err := storageClient.GetBucketLocation(context.Background(), &aws_s3.GetBucketLocationInput{Bucket: b.Name})
+		checkErr(err, fmt.Sprintf("unable to get location of bucket %v, Error: %v", *b.Name, err))
+		region := string(locationOutput.LocationConstraint)
+		if region == "" {
+			region = "us-east-1"
+		}
+		buckets = append(buckets, BucketInfo{Name: *b.Name, Region: region, CreationTime: aws.ToTime(b.CreationDate), ProviderType: ProviderAWS})
 	}
-	_, err := a.getClientWithRegion(target.Region).DeleteBucket(context.Background(), &aws_s3.DeleteBucketInput{Bucket: &target.Bucket})
+	return buckets
+}
+
+func (a AWSStorage) deleteBucket(target GoStorageObject, deleteIfNotEmpty bool) {
+	versions := a.listObjectVersions(GoStorageObject{Bucket: target.Bucket, Region: target.Region})
+	if len(versions) > 0 && !deleteIfNotEmpty {
+		a.config.logger().Warn("bucket is not empty and is not deleted, set deleteIfNotEmpty=true to delete a bucket with its contents", "bucket", target.Bucket)
+		return
+	}
+
+	err := firstDeleteError(a.deleteFiles(versionsToDelete(target, versions)))
+	checkErr(err, fmt.Sprintf("unable to delete contents of bucket %v on AWS, Error %v", target.Bucket, err))
+	_, err = a.getClientWithRegion(target.Region).DeleteBucket(context.Background(), &aws_s3.DeleteBucketInput{Bucket: &target.Bucket})
 	checkErr(err, fmt.Sprintf("unable to delete bucket on AWS, Error %v", err))
 }
 
 func (a AWSStorage) uploadFile(target GoStorageObject, sourceFile string) {
 	_, err := os.Stat(sourceFile)
 	if errors.Is(err, os.ErrNotExist) {
-		fmt.Fprintln(os.Stderr, "Error:", fmt.Sprintf("file %v does not exist", sourceFile))
-		os.Exit(1)
+		fail(nil, fmt.Sprintf("file %v does not exist", sourceFile))
 	}
 	file, err := ioutil.ReadFile(sourceFile)
 	checkErr(err, fmt.Sprintf("unable to read file content from %v, Error: %v", sourceFile, err))
 
-	_, err = a.getClientWithRegion(target.Region).PutObject(context.Background(), &aws_s3.PutObjectInput{Bucket: &target.Bucket, Key: &target.Key, Body: bytes.NewReader(file)})
+	putObjectInput := &aws_s3.PutObjectInput{Bucket: &target.Bucket, Key: &target.Key, Body: bytes.NewReader(file)}
+	if target.ACL != "" {
+		putObjectInput.ACL = types2.ObjectCannedACL(target.ACL)
+	}
+	if target.Metadata != nil {
+		putObjectInput.Metadata = target.Metadata
+	}
+	if target.Tags != nil {
+		putObjectInput.Tagging = aws.String(encodeTags(target.Tags))
+	}
+	if target.Encryption != nil {
+		putObjectInput.ServerSideEncryption, putObjectInput.SSEKMSKeyId = a.serverSideEncryption(target.Encryption)
+	}
+	if target.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 := target.Encryption.customerKeyHeaders()
+		putObjectInput.SSECustomerAlgorithm, putObjectInput.SSECustomerKey, putObjectInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	_, err = a.getClientWithRegion(target.Region).PutObject(context.Background(), putObjectInput)
 	checkErr(err, fmt.Sprintf("unable to write to AWS, Error: %v", err))
 }
 
 func (a AWSStorage) downloadFile(source GoStorageObject, targetFile string) {
-	getObjectOutput, err := a.getClientWithRegion(source.Region).GetObject(context.Background(), &aws_s3.GetObjectInput{Bucket: &source.Bucket, Key: &source.Key})
+	getObjectOutput, err := a.getClientWithRegion(source.Region).GetObject(context.Background(), a.getObjectInput(source))
 	checkErr(err, fmt.Sprintf("unable to read from AWS storage object %v, Error: %v", source.Key, err))
 	defer getObjectOutput.Body.Close()
 
 	data, err := ioutil.ReadAll(getObjectOutput.Body)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", fmt.Sprintf("unable to download contents AWS storage object, Error: %v", err))
-		os.Exit(1)
+		fail(err, fmt.Sprintf("unable to download contents AWS storage object, Error: %v", err))
 	} else {
 		err = ioutil.WriteFile(targetFile, data, 0)
 		checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", targetFile, err))
@@ -71,33 +176,273 @@ func (a AWSStorage) downloadFile(source GoStorageObject, targetFile string) {
 }
 
 func (a AWSStorage) downloadFileAsReader(source GoStorageObject) io.Reader {
-	getObjectOutput, err := a.getClientWithRegion(source.Region).GetObject(context.Background(), &aws_s3.GetObjectInput{Bucket: &source.Bucket, Key: &source.Key})
+	getObjectOutput, err := a.getClientWithRegion(source.Region).GetObject(context.Background(), a.getObjectInput(source))
 	checkErr(err, fmt.Sprintf("unable to read from AWS storage object %v, Error: %v", source.Key, err))
 	return getObjectOutput.Body
 }
 
 func (a AWSStorage) listFilesInBucket(source GoStorageObject) []string {
 	var keys []string
-	listObjects, err := a.getClientWithRegion(source.Region).ListObjectsV2(context.Background(), &aws_s3.ListObjectsV2Input{Bucket: &source.Bucket})
-	checkErr(err, fmt.Sprintf("unable to list files from bucket %v, Error: %v", source.Bucket, err))
-	for _, k := range listObjects.Contents {
-		keys = append(keys, *k.Key)
+	paginator := aws_s3.NewListObjectsV2Paginator(a.getClientWithRegion(source.Region), &aws_s3.ListObjectsV2Input{Bucket: &source.Bucket})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		checkErr(err, fmt.Sprintf("unable to list files from bucket %v, Error: %v", source.Bucket, err))
+		for _, k := range page.Contents {
+			keys = append(keys, *k.Key)
+		}
 	}
 	return keys
 }
 
+// listDirectory lists the keys and common prefixes directly below the prefix source.Key, using "/" as delimiter
+func (a AWSStorage) listDirectory(source GoStorageObject) ([]string, []string) {
+	var keys, prefixes []string
+	paginator := aws_s3.NewListObjectsV2Paginator(a.getClientWithRegion(source.Region), &aws_s3.ListObjectsV2Input{
+		Bucket: &source.Bucket, Prefix: aws.String(source.Key), Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		checkErr(err, fmt.Sprintf("unable to list files from bucket %v, Error: %v", source.Bucket, err))
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+		for _, prefix := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(prefix.Prefix))
+		}
+	}
+	return keys, prefixes
+}
+
+// listObjects lists all objects whose key starts with source.Key
+func (a AWSStorage) listObjects(source GoStorageObject) []ObjectInfo {
+	var objects []ObjectInfo
+	paginator := aws_s3.NewListObjectsV2Paginator(a.getClientWithRegion(source.Region), &aws_s3.ListObjectsV2Input{
+		Bucket: &source.Bucket, Prefix: aws.String(source.Key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		checkErr(err, fmt.Sprintf("unable to list files from bucket %v, Error: %v", source.Bucket, err))
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         object.Size,
+				ETag:         aws.ToString(object.ETag),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return objects
+}
+
 func (a AWSStorage) deleteFile(target GoStorageObject) {
-	_, err := a.getClientWithRegion(target.Region).DeleteObject(context.Background(), &aws_s3.DeleteObjectInput{Bucket: &target.Bucket, Key: &target.Key})
+	deleteObjectInput := &aws_s3.DeleteObjectInput{Bucket: &target.Bucket, Key: &target.Key}
+	if target.VersionId != "" {
+		deleteObjectInput.VersionId = &target.VersionId
+	}
+	_, err := a.getClientWithRegion(target.Region).DeleteObject(context.Background(), deleteObjectInput)
 	checkErr(err, fmt.Sprintf("unable to delete file %v, Error: %v", target.Key, err))
 }
 
+// deleteFiles deletes the objects (all in the same bucket) with DeleteObjects in batches of AWSDeleteObjectsBatchSize keys
+func (a AWSStorage) deleteFiles(targets []GoStorageObject) []DeleteResult {
+	results := make([]DeleteResult, len(targets))
+	for start := 0; start < len(targets); start += AWSDeleteObjectsBatchSize {
+		end := start + AWSDeleteObjectsBatchSize
+		if end > len(targets) {
+			end = len(targets)
+		}
+		batch := targets[start:end]
+		identifiers := make([]types2.ObjectIdentifier, len(batch))
+		for i, target := range batch {
+			results[start+i] = DeleteResult{Key: target.Key, VersionId: target.VersionId}
+			identifiers[i] = types2.ObjectIdentifier{Key: aws.String(target.Key)}
+			if target.VersionId != "" {
+				identifiers[i].VersionId = aws.String(target.VersionId)
+			}
+		}
+
+		deleteOutput, err := a.getClientWithRegion(batch[0].Region).DeleteObjects(context.Background(), &aws_s3.DeleteObjectsInput{
+			Bucket: aws.String(batch[0].Bucket),
+			Delete: &types2.Delete{Objects: identifiers, Quiet: true},
+		})
+		if err != nil {
+			for i := start; i < end; i++ {
+				results[i].Err = err
+			}
+			continue
+		}
+		for _, deleteError := range deleteOutput.Errors {
+			for i := start; i < end; i++ {
+				if results[i].Key == aws.ToString(deleteError.Key) && results[i].VersionId == aws.ToString(deleteError.VersionId) {
+					results[i].Err = fmt.Errorf("unable to delete file %v, Error: %v: %v", results[i].Key, aws.ToString(deleteError.Code), aws.ToString(deleteError.Message))
+				}
+			}
+		}
+	}
+	return results
+}
+
+func (a AWSStorage) statObject(source GoStorageObject) ObjectInfo {
+	headObjectOutput, err := a.getClientWithRegion(source.Region).HeadObject(context.Background(), a.headObjectInput(source))
+	checkErr(err, fmt.Sprintf("unable to get attributes of AWS storage object %v, Error: %v", source.Key, err))
+	return ObjectInfo{
+		Key:          source.Key,
+		VersionId:    aws.ToString(headObjectOutput.VersionId),
+		Size:         headObjectOutput.ContentLength,
+		ETag:         aws.ToString(headObjectOutput.ETag),
+		LastModified: aws.ToTime(headObjectOutput.LastModified),
+		Metadata:     headObjectOutput.Metadata,
+	}
+}
+
+// setObjectMetadata replaces the metadata of the object with target.Metadata by copying the object onto itself. The
+// server-side encryption, content headers and storage class of the object are kept, objects larger than
+// AWSMaxCopyObjectSize are copied in parts.
+func (a AWSStorage) setObjectMetadata(target GoStorageObject) {
+	storageClient := a.getClientWithRegion(target.Region)
+	head, err := storageClient.HeadObject(context.Background(), a.headObjectInput(target))
+	checkErr(err, fmt.Sprintf("unable to get attributes of AWS storage object %v, Error: %v", target.Key, err))
+	metadata := target.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	sourceString := fmt.Sprintf("%v/%v", target.Bucket, target.Key)
+	if target.VersionId != "" {
+		sourceString = fmt.Sprintf("%v?versionId=%v", sourceString, target.VersionId)
+	}
+	if head.ContentLength > AWSMaxCopyObjectSize {
+		a.copyObjectInParts(target, sourceString, head, metadata)
+		return
+	}
+
+	copyObjectInput := &aws_s3.CopyObjectInput{Bucket: &target.Bucket, CopySource: &sourceString, Key: &target.Key,
+		MetadataDirective: types2.MetadataDirectiveReplace, Metadata: metadata, StorageClass: head.StorageClass,
+		ContentType: head.ContentType, ContentEncoding: head.ContentEncoding, ContentLanguage: head.ContentLanguage,
+		ContentDisposition: head.ContentDisposition, CacheControl: head.CacheControl,
+		ServerSideEncryption: head.ServerSideEncryption, SSEKMSKeyId: head.SSEKMSKeyId, BucketKeyEnabled: head.BucketKeyEnabled}
+	if target.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 := target.Encryption.customerKeyHeaders()
+		copyObjectInput.CopySourceSSECustomerAlgorithm, copyObjectInput.CopySourceSSECustomerKey, copyObjectInput.CopySourceSSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+		copyObjectInput.SSECustomerAlgorithm, copyObjectInput.SSECustomerKey, copyObjectInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	_, err = storageClient.CopyObject(context.Background(), copyObjectInput)
+	checkErr(err, fmt.Sprintf("unable to set metadata of object %v, Error: %v", target.Key, err))
+}
+
+// copyObjectInParts copies sourceString (described by head) onto target with a multipart upload, the upload is aborted
+// if a part can not be copied
+func (a AWSStorage) copyObjectInParts(target GoStorageObject, sourceString string, head *aws_s3.HeadObjectOutput, metadata map[string]string) {
+	storageClient := a.getClientWithRegion(target.Region)
+	createInput := &aws_s3.CreateMultipartUploadInput{Bucket: &target.Bucket, Key: &target.Key, Metadata: metadata,
+		StorageClass: head.StorageClass, ContentType: head.ContentType, ContentEncoding: head.ContentEncoding,
+		ContentLanguage: head.ContentLanguage, ContentDisposition: head.ContentDisposition, CacheControl: head.CacheControl,
+		ServerSideEncryption: head.ServerSideEncryption, SSEKMSKeyId: head.SSEKMSKeyId, BucketKeyEnabled: head.BucketKeyEnabled}
+	var customerKey, customerKeyMD5 string
+	if target.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 = target.Encryption.customerKeyHeaders()
+		createInput.SSECustomerAlgorithm, createInput.SSECustomerKey, createInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	upload, err := storageClient.CreateMultipartUpload(context.Background(), createInput)
+	checkErr(err, fmt.Sprintf("unable to set metadata of object %v, Error: %v", target.Key, err))
+	abort := func(err error) {
+		storageClient.AbortMultipartUpload(context.Background(), &aws_s3.AbortMultipartUploadInput{Bucket: &target.Bucket,
+			Key: &target.Key, UploadId: upload.UploadId})
+		fail(err, fmt.Sprintf("unable to set metadata of object %v, Error: %v", target.Key, err))
+	}
+
+	// a multipart upload has at most 10000 parts
+	partSize := int64(AWSCopyPartSize)
+	for head.ContentLength > partSize*10000 {
+		partSize *= 2
+	}
+	var parts []types2.CompletedPart
+	for start, partNumber := int64(0), int32(1); start < head.ContentLength; start, partNumber = start+partSize, partNumber+1 {
+		end := start + partSize
+		if end > head.ContentLength {
+			end = head.ContentLength
+		}
+		partInput := &aws_s3.UploadPartCopyInput{Bucket: &target.Bucket, Key: &target.Key, UploadId: upload.UploadId,
+			PartNumber: partNumber, CopySource: &sourceString, CopySourceRange: aws.String(fmt.Sprintf("bytes=%v-%v", start, end-1))}
+		if target.Encryption.usesCustomerKey() {
+			partInput.CopySourceSSECustomerAlgorithm, partInput.CopySourceSSECustomerKey, partInput.CopySourceSSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+			partInput.SSECustomerAlgorithm, partInput.SSECustomerKey, partInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+		}
+		part, err := storageClient.UploadPartCopy(context.Background(), partInput)
+		if err != nil {
+			abort(err)
+		}
+		parts = append(parts, types2.CompletedPart{ETag: part.CopyPartResult.ETag, PartNumber: partNumber})
+	}
+
+	completeInput := &aws_s3.CompleteMultipartUploadInput{Bucket: &target.Bucket, Key: &target.Key, UploadId: upload.UploadId,
+		MultipartUpload: &types2.CompletedMultipartUpload{Parts: parts}}
+	if target.Encryption.usesCustomerKey() {
+		completeInput.SSECustomerAlgorithm, completeInput.SSECustomerKey, completeInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	if _, err = storageClient.CompleteMultipartUpload(context.Background(), completeInput); err != nil {
+		abort(err)
+	}
+}
+
+// isModified checks with a conditional request (If-None-Match) whether source has changed since it had the ETag of cached
+func (a AWSStorage) isModified(source GoStorageObject, cached ObjectInfo) bool {
+	headObjectInput := a.headObjectInput(source)
+	headObjectInput.IfNoneMatch = aws.String(cached.ETag)
+	_, err := a.getClientWithRegion(source.Region).HeadObject(context.Background(), headObjectInput)
+	var responseError *awshttp.ResponseError
+	if errors.As(err, &responseError) && responseError.HTTPStatusCode() == http.StatusNotModified {
+		return false
+	}
+	return true
+}
+
+// canAccess checks whether the credentials of this provider are permitted to read source (object or bucket)
+func (a AWSStorage) canAccess(source GoStorageObject) bool {
+	if source.Key == "" {
+		_, err := a.getClientWithRegion(source.Region).HeadBucket(context.Background(), &aws_s3.HeadBucketInput{Bucket: &source.Bucket})
+		return err == nil
+	}
+	headObjectInput := &aws_s3.HeadObjectInput{Bucket: &source.Bucket, Key: &source.Key}
+	if source.VersionId != "" {
+		headObjectInput.VersionId = &source.VersionId
+	}
+	_, err := a.getClientWithRegion(source.Region).HeadObject(context.Background(), headObjectInput)
+	return err == nil
+}
+
 func (a AWSStorage) copyFileWithinProvider(source GoStorageObject, target GoStorageObject) {
 	storageClient := a.getClientWithRegion(source.Region)
-	if target.Region != "" && target.Region != DefaultAWSRegion {
+	if target.Region != "" && target.Region != a.config.awsRegion() {
 		storageClient = a.getClientWithRegion(target.Region)
 	}
 	sourceString := fmt.Sprintf("%v/%v", source.Bucket, source.Key)
-	_, err := storageClient.CopyObject(context.Background(), &aws_s3.CopyObjectInput{Bucket: &target.Bucket, CopySource: &sourceString, Key: &target.Key})
+	if source.VersionId != "" {
+		sourceString = fmt.Sprintf("%v?versionId=%v", sourceString, source.VersionId)
+	}
+	copyObjectInput := &aws_s3.CopyObjectInput{Bucket: &target.Bucket, CopySource: &sourceString, Key: &target.Key}
+	if target.ACL != "" {
+		copyObjectInput.ACL = types2.ObjectCannedACL(target.ACL)
+	}
+	if target.Metadata != nil {
+		copyObjectInput.MetadataDirective = types2.MetadataDirectiveReplace
+		copyObjectInput.Metadata = target.Metadata
+	}
+	if target.Tags != nil {
+		copyObjectInput.TaggingDirective = types2.TaggingDirectiveReplace
+		copyObjectInput.Tagging = aws.String(encodeTags(target.Tags))
+	}
+	if source.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 := source.Encryption.customerKeyHeaders()
+		copyObjectInput.CopySourceSSECustomerAlgorithm, copyObjectInput.CopySourceSSECustomerKey, copyObjectInput.CopySourceSSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	if target.Encryption != nil {
+		copyObjectInput.ServerSideEncryption, copyObjectInput.SSEKMSKeyId = a.serverSideEncryption(target.Encryption)
+	}
+	if target.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 := target.Encryption.customerKeyHeaders()
+		copyObjectInput.SSECustomerAlgorithm, copyObjectInput.SSECustomerKey, copyObjectInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	_, err := storageClient.CopyObject(context.Background(), copyObjectInput)
 	checkErr(err, fmt.Sprintf("unable to copy object from %v to %v, Error: %v", source.Bucket, target.Bucket, err))
 }
 
@@ -110,13 +455,361 @@ func (a AWSStorage) copyBucketWithinProvider(source GoStorageObject, target GoSt
 	}
 }
 
+func (a AWSStorage) setBucketVersioning(target GoStorageObject, enabled bool) {
+	status := types2.BucketVersioningStatusSuspended
+	if enabled {
+		status = types2.BucketVersioningStatusEnabled
+	}
+	_, err := a.getClientWithRegion(target.Region).PutBucketVersioning(context.Background(), &aws_s3.PutBucketVersioningInput{
+		Bucket:                  &target.Bucket,
+		VersioningConfiguration: &types2.VersioningConfiguration{Status: status},
+	})
+	checkErr(err, fmt.Sprintf("unable to change versioning of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) listObjectVersions(source GoStorageObject) []ObjectVersion {
+	var versions []ObjectVersion
+	storageClient := a.getClientWithRegion(source.Region)
+	listInput := &aws_s3.ListObjectVersionsInput{Bucket: &source.Bucket}
+	if source.Key != "" {
+		listInput.Prefix = &source.Key
+	}
+	for {
+		listOutput, err := storageClient.ListObjectVersions(context.Background(), listInput)
+		checkErr(err, fmt.Sprintf("unable to list object versions from bucket %v, Error: %v", source.Bucket, err))
+		for _, v := range listOutput.Versions {
+			versions = append(versions, ObjectVersion{Key: *v.Key, VersionId: *v.VersionId, IsLatest: v.IsLatest, Size: v.Size, LastModified: *v.LastModified})
+		}
+		for _, m := range listOutput.DeleteMarkers {
+			versions = append(versions, ObjectVersion{Key: *m.Key, VersionId: *m.VersionId, IsLatest: m.IsLatest, IsDeleteMarker: true, LastModified: *m.LastModified})
+		}
+		if !listOutput.IsTruncated {
+			break
+		}
+		listInput.KeyMarker = listOutput.NextKeyMarker
+		listInput.VersionIdMarker = listOutput.NextVersionIdMarker
+	}
+	if source.Key != "" {
+		return filterVersionsByKey(versions, source.Key)
+	}
+	return versions
+}
+
+func (a AWSStorage) restoreObjectVersion(source GoStorageObject) {
+	target := source
+	target.VersionId = ""
+	a.copyFileWithinProvider(source, target)
+}
+
+func (a AWSStorage) getLifecycleRules(target GoStorageObject) []LifecycleRule {
+	var rules []LifecycleRule
+	lifecycleOutput, err := a.getClientWithRegion(target.Region).GetBucketLifecycleConfiguration(context.Background(), &aws_s3.GetBucketLifecycleConfigurationInput{Bucket: &target.Bucket})
+	if hasAWSErrorCode(err, "NoSuchLifecycleConfiguration") {
+		return rules
+	}
+	checkErr(err, fmt.Sprintf("unable to get lifecycle rules of bucket %v, Error: %v", target.Bucket, err))
+
+	for _, r := range lifecycleOutput.Rules {
+		rule := LifecycleRule{}
+		if r.ID != nil {
+			rule.ID = *r.ID
+		}
+		if prefixFilter, ok := r.Filter.(*types2.LifecycleRuleFilterMemberPrefix); ok {
+			rule.Prefix = prefixFilter.Value
+		} else if r.Prefix != nil {
+			rule.Prefix = *r.Prefix
+		}
+		if r.Expiration != nil {
+			rule.ExpirationDays = int(r.Expiration.Days)
+		}
+		if r.AbortIncompleteMultipartUpload != nil {
+			rule.AbortIncompleteMultipartUploadDays = int(r.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+		}
+		if r.NoncurrentVersionExpiration != nil {
+			rule.NoncurrentVersionExpirationDays = int(r.NoncurrentVersionExpiration.NoncurrentDays)
+			rule.NoncurrentVersionsToKeep = int(r.NoncurrentVersionExpiration.NewerNoncurrentVersions)
+		}
+		for i, t := range r.Transitions {
+			if i == 0 {
+				rule.TransitionDays = int(t.Days)
+				rule.TransitionStorageClass = string(t.StorageClass)
+			} else {
+				rules = append(rules, LifecycleRule{ID: rule.ID, Prefix: rule.Prefix, TransitionDays: int(t.Days), TransitionStorageClass: string(t.StorageClass)})
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// setLifecycleRules combines rules with the same ID into one AWS rule, so the rules returned by getLifecycleRules (one per
+// transition) can be set again. The lifecycle configuration is deleted if there are no rules, as S3 rejects empty ones.
+func (a AWSStorage) setLifecycleRules(target GoStorageObject, rules []LifecycleRule) {
+	if len(rules) == 0 {
+		a.deleteLifecycleRules(target)
+		return
+	}
+	var awsRules []types2.LifecycleRule
+	ruleIndexes := map[string]int{}
+	for _, rule := range rules {
+		if index, ok := ruleIndexes[rule.ID]; ok {
+			mergeLifecycleRule(&awsRules[index], rule, target)
+			continue
+		}
+		awsRule := types2.LifecycleRule{
+			Status: types2.ExpirationStatusEnabled,
+			Filter: &types2.LifecycleRuleFilterMemberPrefix{Value: rule.Prefix},
+		}
+		if rule.ID != "" {
+			awsRule.ID = aws.String(rule.ID)
+			ruleIndexes[rule.ID] = len(awsRules)
+		}
+		if rule.ExpirationDays != 0 {
+			awsRule.Expiration = &types2.LifecycleExpiration{Days: int32(rule.ExpirationDays)}
+		}
+		if rule.TransitionDays != 0 {
+			awsRule.Transitions = []types2.Transition{{Days: int32(rule.TransitionDays), StorageClass: types2.TransitionStorageClass(rule.TransitionStorageClass)}}
+		}
+		if rule.AbortIncompleteMultipartUploadDays != 0 {
+			awsRule.AbortIncompleteMultipartUpload = &types2.AbortIncompleteMultipartUpload{DaysAfterInitiation: int32(rule.AbortIncompleteMultipartUploadDays)}
+		}
+		if rule.NoncurrentVersionExpirationDays != 0 {
+			awsRule.NoncurrentVersionExpiration = &types2.NoncurrentVersionExpiration{
+				NoncurrentDays:          int32(rule.NoncurrentVersionExpirationDays),
+				NewerNoncurrentVersions: int32(rule.NoncurrentVersionsToKeep),
+			}
+		}
+		awsRules = append(awsRules, awsRule)
+	}
+	_, err := a.getClientWithRegion(target.Region).PutBucketLifecycleConfiguration(context.Background(), &aws_s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 &target.Bucket,
+		LifecycleConfiguration: &types2.BucketLifecycleConfiguration{Rules: awsRules},
+	})
+	checkErr(err, fmt.Sprintf("unable to set lifecycle rules of bucket %v, Error: %v", target.Bucket, err))
+}
+
+// mergeLifecycleRule adds the actions of rule to awsRule, rules with the same ID must have the same prefix and may set
+// each action other than transitions only once
+func mergeLifecycleRule(awsRule *types2.LifecycleRule, rule LifecycleRule, target GoStorageObject) {
+	if awsRule.Filter.(*types2.LifecycleRuleFilterMemberPrefix).Value != rule.Prefix {
+		fail(nil, fmt.Sprintf("unable to set lifecycle rules of bucket %v, rules with ID %v have different prefixes", target.Bucket, rule.ID))
+	}
+	conflict := (rule.ExpirationDays != 0 && awsRule.Expiration != nil) ||
+		(rule.AbortIncompleteMultipartUploadDays != 0 && awsRule.AbortIncompleteMultipartUpload != nil) ||
+		(rule.NoncurrentVersionExpirationDays != 0 && awsRule.NoncurrentVersionExpiration != nil)
+	if conflict {
+		fail(nil, fmt.Sprintf("unable to set lifecycle rules of bucket %v, rules with ID %v set the same action", target.Bucket, rule.ID))
+	}
+	if rule.ExpirationDays != 0 {
+		awsRule.Expiration = &types2.LifecycleExpiration{Days: int32(rule.ExpirationDays)}
+	}
+	if rule.TransitionDays != 0 {
+		awsRule.Transitions = append(awsRule.Transitions, types2.Transition{Days: int32(rule.TransitionDays), StorageClass: types2.TransitionStorageClass(rule.TransitionStorageClass)})
+	}
+	if rule.AbortIncompleteMultipartUploadDays != 0 {
+		awsRule.AbortIncompleteMultipartUpload = &types2.AbortIncompleteMultipartUpload{DaysAfterInitiation: int32(rule.AbortIncompleteMultipartUploadDays)}
+	}
+	if rule.NoncurrentVersionExpirationDays != 0 {
+		awsRule.NoncurrentVersionExpiration = &types2.NoncurrentVersionExpiration{
+			NoncurrentDays:          int32(rule.NoncurrentVersionExpirationDays),
+			NewerNoncurrentVersions: int32(rule.NoncurrentVersionsToKeep),
+		}
+	}
+}
+
+func (a AWSStorage) deleteLifecycleRules(target GoStorageObject) {
+	_, err := a.getClientWithRegion(target.Region).DeleteBucketLifecycle(context.Background(), &aws_s3.DeleteBucketLifecycleInput{Bucket: &target.Bucket})
+	checkErr(err, fmt.Sprintf("unable to delete lifecycle rules of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) setObjectACL(target GoStorageObject, acl ACL) {
+	_, err := a.getClientWithRegion(target.Region).PutObjectAcl(context.Background(), &aws_s3.PutObjectAclInput{Bucket: &target.Bucket, Key: &target.Key, ACL: types2.ObjectCannedACL(acl)})
+	checkErr(err, fmt.Sprintf("unable to set ACL of object %v, Error: %v", target.Key, err))
+}
+
+func (a AWSStorage) setPublicAccessBlock(target GoStorageObject, blocked bool) {
+	_, err := a.getClientWithRegion(target.Region).PutPublicAccessBlock(context.Background(), &aws_s3.PutPublicAccessBlockInput{
+		Bucket: &target.Bucket,
+		PublicAccessBlockConfiguration: &types2.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       blocked,
+			BlockPublicPolicy:     blocked,
+			IgnorePublicAcls:      blocked,
+			RestrictPublicBuckets: blocked,
+		},
+	})
+	checkErr(err, fmt.Sprintf("unable to change public access block of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) setUniformBucketLevelAccess(target GoStorageObject, enabled bool) {
+	objectOwnership := types2.ObjectOwnershipObjectWriter
+	if enabled {
+		objectOwnership = types2.ObjectOwnershipBucketOwnerEnforced
+	}
+	_, err := a.getClientWithRegion(target.Region).PutBucketOwnershipControls(context.Background(), &aws_s3.PutBucketOwnershipControlsInput{
+		Bucket:            &target.Bucket,
+		OwnershipControls: &types2.OwnershipControls{Rules: []types2.OwnershipControlsRule{{ObjectOwnership: objectOwnership}}},
+	})
+	checkErr(err, fmt.Sprintf("unable to change object ownership of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) getBucketPolicy(target GoStorageObject) string {
+	policyOutput, err := a.getClientWithRegion(target.Region).GetBucketPolicy(context.Background(), &aws_s3.GetBucketPolicyInput{Bucket: &target.Bucket})
+	if hasAWSErrorCode(err, "NoSuchBucketPolicy") {
+		return ""
+	}
+	checkErr(err, fmt.Sprintf("unable to get policy of bucket %v, Error: %v", target.Bucket, err))
+	return aws.ToString(policyOutput.Policy)
+}
+
+func (a AWSStorage) setBucketPolicy(target GoStorageObject, policy string) {
+	_, err := a.getClientWithRegion(target.Region).PutBucketPolicy(context.Background(), &aws_s3.PutBucketPolicyInput{Bucket: &target.Bucket, Policy: &policy})
+	checkErr(err, fmt.Sprintf("unable to set policy of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) setDefaultBucketEncryption(target GoStorageObject, encryption Encryption) {
+	sseAlgorithm, kmsKeyId := a.serverSideEncryption(&encryption)
+	_, err := a.getClientWithRegion(target.Region).PutBucketEncryption(context.Background(), &aws_s3.PutBucketEncryptionInput{
+		Bucket: &target.Bucket,
+		ServerSideEncryptionConfiguration: &types2.ServerSideEncryptionConfiguration{Rules: []types2.ServerSideEncryptionRule{{
+			ApplyServerSideEncryptionByDefault: &types2.ServerSideEncryptionByDefault{SSEAlgorithm: sseAlgorithm, KMSMasterKeyID: kmsKeyId},
+		}}},
+	})
+	checkErr(err, fmt.Sprintf("unable to set default encryption of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) getObjectTags(target GoStorageObject) map[string]string {
+	taggingInput := &aws_s3.GetObjectTaggingInput{Bucket: &target.Bucket, Key: &target.Key}
+	if target.VersionId != "" {
+		taggingInput.VersionId = &target.VersionId
+	}
+	taggingOutput, err := a.getClientWithRegion(target.Region).GetObjectTagging(context.Background(), taggingInput)
+	checkErr(err, fmt.Sprintf("unable to get tags of AWS storage object %v, Error: %v", target.Key, err))
+	return tagsFromAWSTagSet(taggingOutput.TagSet)
+}
+
+func (a AWSStorage) setObjectTags(target GoStorageObject, tags map[string]string) {
+	taggingInput := &aws_s3.PutObjectTaggingInput{Bucket: &target.Bucket, Key: &target.Key, Tagging: &types2.Tagging{TagSet: awsTagSet(tags)}}
+	if target.VersionId != "" {
+		taggingInput.VersionId = &target.VersionId
+	}
+	_, err := a.getClientWithRegion(target.Region).PutObjectTagging(context.Background(), taggingInput)
+	checkErr(err, fmt.Sprintf("unable to set tags of AWS storage object %v, Error: %v", target.Key, err))
+}
+
+func (a AWSStorage) deleteObjectTags(target GoStorageObject) {
+	taggingInput := &aws_s3.DeleteObjectTaggingInput{Bucket: &target.Bucket, Key: &target.Key}
+	if target.VersionId != "" {
+		taggingInput.VersionId = &target.VersionId
+	}
+	_, err := a.getClientWithRegion(target.Region).DeleteObjectTagging(context.Background(), taggingInput)
+	checkErr(err, fmt.Sprintf("unable to delete tags of AWS storage object %v, Error: %v", target.Key, err))
+}
+
+func (a AWSStorage) getBucketTags(target GoStorageObject) map[string]string {
+	taggingOutput, err := a.getClientWithRegion(target.Region).GetBucketTagging(context.Background(), &aws_s3.GetBucketTaggingInput{Bucket: &target.Bucket})
+	if hasAWSErrorCode(err, "NoSuchTagSet") {
+		return map[string]string{}
+	}
+	checkErr(err, fmt.Sprintf("unable to get tags of bucket %v, Error: %v", target.Bucket, err))
+	return tagsFromAWSTagSet(taggingOutput.TagSet)
+}
+
+func (a AWSStorage) setBucketTags(target GoStorageObject, tags map[string]string) {
+	if len(tags) == 0 {
+		a.deleteBucketTags(target)
+		return
+	}
+	_, err := a.getClientWithRegion(target.Region).PutBucketTagging(context.Background(), &aws_s3.PutBucketTaggingInput{Bucket: &target.Bucket, Tagging: &types2.Tagging{TagSet: awsTagSet(tags)}})
+	checkErr(err, fmt.Sprintf("unable to set tags of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (a AWSStorage) deleteBucketTags(target GoStorageObject) {
+	_, err := a.getClientWithRegion(target.Region).DeleteBucketTagging(context.Background(), &aws_s3.DeleteBucketTaggingInput{Bucket: &target.Bucket})
+	checkErr(err, fmt.Sprintf("unable to delete tags of bucket %v, Error: %v", target.Bucket, err))
+}
+
+// listFilesWithTags requests the tags of every object starting with source.Key, S3 does not support filtering by tags
+func (a AWSStorage) listFilesWithTags(source GoStorageObject, tags map[string]string) []string {
+	var keys []string
+	for _, object := range a.listObjects(source) {
+		if hasTags(a.getObjectTags(GoStorageObject{Bucket: source.Bucket, Key: object.Key, Region: source.Region}), tags) {
+			keys = append(keys, object.Key)
+		}
+	}
+	return keys
+}
+
+// serverSideEncryption maps the encryption settings to the AWS SSE algorithm and KMS key id, customer keys are handled separately
+func (a AWSStorage) serverSideEncryption(encryption *Encryption) (types2.ServerSideEncryption, *string) {
+	switch encryption.Type {
+	case EncryptionProviderManaged:
+		return types2.ServerSideEncryptionAes256, nil
+	case EncryptionKMS:
+		if encryption.KMSKeyId == "" {
+			return types2.ServerSideEncryptionAwsKms, nil
+		}
+		return types2.ServerSideEncryptionAwsKms, aws.String(encryption.KMSKeyId)
+	default:
+		return "", nil
+	}
+}
+
+func (a AWSStorage) getObjectInput(source GoStorageObject) *aws_s3.GetObjectInput {
+	getObjectInput := &aws_s3.GetObjectInput{Bucket: &source.Bucket, Key: &source.Key}
+	if source.VersionId != "" {
+		getObjectInput.VersionId = &source.VersionId
+	}
+	if source.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 := source.Encryption.customerKeyHeaders()
+		getObjectInput.SSECustomerAlgorithm, getObjectInput.SSECustomerKey, getObjectInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	return getObjectInput
+}
+
+func (a AWSStorage) headObjectInput(source GoStorageObject) *aws_s3.HeadObjectInput {
+	headObjectInput := &aws_s3.HeadObjectInput{Bucket: &source.Bucket, Key: &source.Key}
+	if source.VersionId != "" {
+		headObjectInput.VersionId = &source.VersionId
+	}
+	if source.Encryption.usesCustomerKey() {
+		customerKey, customerKeyMD5 := source.Encryption.customerKeyHeaders()
+		headObjectInput.SSECustomerAlgorithm, headObjectInput.SSECustomerKey, headObjectInput.SSECustomerKeyMD5 = aws.String("AES256"), &customerKey, &customerKeyMD5
+	}
+	return headObjectInput
+}
+
+func awsTagSet(tags map[string]string) []types2.Tag {
+	tagSet := []types2.Tag{}
+	for _, k := range sortedTagKeys(tags) {
+		tagSet = append(tagSet, types2.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return tagSet
+}
+
+func tagsFromAWSTagSet(tagSet []types2.Tag) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range tagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags
+}
+
 func (a AWSStorage) getClientWithRegion(region string) *aws_s3.Client {
 	if region == "" {
-		region = DefaultAWSRegion
+		region = a.config.awsRegion()
 	}
-
-	staticCredentialsProvider := credentials.StaticCredentialsProvider{Value: *a.CredentialsHolder.AwsCredentials}
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region), config.WithCredentialsProvider(staticCredentialsProvider))
-	checkErr(err, fmt.Sprintf("unable to load AWS SDK config, Error: %v", err))
-	return aws_s3.NewFromConfig(cfg)
+	return a.clients.getAWSClient(a.account, region, func() *aws_s3.Client {
+		credentialsProvider := a.CredentialsHolder.awsCredentialsProvider()
+		if credentialsProvider == nil {
+			fail(nil, "unable to create AWS storage client, no AWS credentials configured")
+		}
+		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region), config.WithCredentialsProvider(credentialsProvider))
+		checkErr(err, fmt.Sprintf("unable to load AWS SDK config, Error: %v", err))
+		return aws_s3.NewFromConfig(cfg, func(options *aws_s3.Options) {
+			if a.config.AWSEndpoint != "" {
+				options.EndpointResolver = aws_s3.EndpointResolverFromURL(a.config.AWSEndpoint)
+				options.UsePathStyle = true
+			}
+		})
+	})
 }
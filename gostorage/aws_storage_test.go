@@ -0,0 +1,206 @@
+package gostorage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	aws_s3 "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// newFakeAWSStorage returns an AWSStorage whose requests are served by handler
+func newFakeAWSStorage(t *testing.T, handler http.HandlerFunc) AWSStorage {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := aws_s3.New(aws_s3.Options{Region: DefaultAWSRegion,
+		Credentials:      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		EndpointResolver: aws_s3.EndpointResolverFromURL(server.URL), UsePathStyle: true})
+	return AWSStorage{clients: &clientCache{awsClients: map[string]*aws_s3.Client{"/" + DefaultAWSRegion: client}}}
+}
+
+// kmsObjectHeaders writes the response headers of a HEAD request for a SSE-KMS encrypted object of size bytes
+func kmsObjectHeaders(w http.ResponseWriter, size int64) {
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("x-amz-server-side-encryption", "aws:kms")
+	w.Header().Set("x-amz-server-side-encryption-aws-kms-key-id", "key-id")
+}
+
+func TestAWSSetObjectMetadataKeepsEncryption(t *testing.T) {
+	var copyRequest *http.Request
+	a := newFakeAWSStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			kmsObjectHeaders(w, 10)
+		case http.MethodPut:
+			copyRequest = r
+			w.Write([]byte(`<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	object := GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderAWS, Metadata: map[string]string{"owner": "test"}}
+	if err := catchErrors(func() { a.setObjectMetadata(object) }); err != nil {
+		t.Fatal(err)
+	}
+	if copyRequest == nil {
+		t.Fatal("object was not copied")
+	}
+	expected := map[string]string{
+		"x-amz-copy-source":                           "bucket/key",
+		"x-amz-metadata-directive":                    "REPLACE",
+		"x-amz-meta-owner":                            "test",
+		"x-amz-server-side-encryption":                "aws:kms",
+		"x-amz-server-side-encryption-aws-kms-key-id": "key-id",
+		"Content-Type":                                "text/plain",
+	}
+	for header, value := range expected {
+		if got := copyRequest.Header.Get(header); got != value {
+			t.Errorf("copy request has %v %q, expected %q", header, got, value)
+		}
+	}
+}
+
+func TestAWSSetObjectMetadataCopiesLargeObjectsInParts(t *testing.T) {
+	size := int64(AWSMaxCopyObjectSize + 1)
+	var mutex sync.Mutex
+	var createRequest *http.Request
+	var ranges []string
+	completed := false
+	a := newFakeAWSStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodHead:
+			kmsObjectHeaders(w, size)
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			createRequest = r
+			w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut && query.Get("uploadId") == "upload":
+			ranges = append(ranges, r.Header.Get("x-amz-copy-source-range"))
+			w.Write([]byte(fmt.Sprintf(`<CopyPartResult><ETag>"part-%v"</ETag></CopyPartResult>`, query.Get("partNumber"))))
+		case r.Method == http.MethodPost && query.Get("uploadId") == "upload":
+			completed = true
+			w.Write([]byte(`<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	object := GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderAWS, Metadata: map[string]string{"owner": "test"}}
+	if err := catchErrors(func() { a.setObjectMetadata(object) }); err != nil {
+		t.Fatal(err)
+	}
+	if createRequest == nil || !completed {
+		t.Fatal("object was not copied with a multipart upload")
+	}
+	if got := createRequest.Header.Get("x-amz-server-side-encryption-aws-kms-key-id"); got != "key-id" {
+		t.Errorf("multipart upload uses KMS key %q", got)
+	}
+	if got := createRequest.Header.Get("x-amz-meta-owner"); got != "test" {
+		t.Errorf("multipart upload has owner %q", got)
+	}
+	var next int64
+	for _, byteRange := range ranges {
+		var start, end int64
+		fmt.Sscanf(byteRange, "bytes=%d-%d", &start, &end)
+		if start != next || end < start {
+			t.Fatalf("parts %v do not cover the object", strings.Join(ranges, ", "))
+		}
+		next = end + 1
+	}
+	if next != size {
+		t.Errorf("parts %v cover %v bytes, expected %v", strings.Join(ranges, ", "), next, size)
+	}
+}
+
+func TestAWSLifecycleRulesRoundTrip(t *testing.T) {
+	var putBody string
+	a := newFakeAWSStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`<LifecycleConfiguration><Rule><ID>archive</ID><Filter><Prefix>logs/</Prefix></Filter><Status>Enabled</Status>
+<Transition><Days>30</Days><StorageClass>STANDARD_IA</StorageClass></Transition>
+<Transition><Days>90</Days><StorageClass>GLACIER</StorageClass></Transition>
+<Expiration><Days>365</Days></Expiration></Rule></LifecycleConfiguration>`))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			putBody = string(body)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	bucket := GoStorageObject{Bucket: "bucket", ProviderType: ProviderAWS}
+	var rules []LifecycleRule
+	if err := catchErrors(func() { rules = a.getLifecycleRules(bucket) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("rule with two transitions was returned as %v", rules)
+	}
+	if err := catchErrors(func() { a.setLifecycleRules(bucket, rules) }); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(putBody, "<Rule>") != 1 || strings.Count(putBody, "<Transition>") != 2 || strings.Count(putBody, "<Expiration>") != 1 {
+		t.Errorf("rules were not combined into one rule: %v", putBody)
+	}
+}
+
+func TestAWSSetNoLifecycleRules(t *testing.T) {
+	var method string
+	a := newFakeAWSStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if err := catchErrors(func() { a.setLifecycleRules(GoStorageObject{Bucket: "bucket", ProviderType: ProviderAWS}, nil) }); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodDelete {
+		t.Errorf("setting no rules sent a %v request instead of deleting the lifecycle configuration", method)
+	}
+}
+
+func TestAWSBucketStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   BucketStatus
+	}{
+		{http.StatusOK, BucketAccessible},
+		{http.StatusNotFound, BucketNotFound},
+		{http.StatusForbidden, BucketInaccessible},
+		{http.StatusMovedPermanently, BucketInaccessible},
+	}
+	for _, test := range tests {
+		a := newFakeAWSStorage(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("x-amz-bucket-region", "eu-central-1")
+			w.WriteHeader(test.statusCode)
+		})
+		var status BucketStatus
+		if err := catchErrors(func() { status = a.bucketStatus(GoStorageObject{Bucket: "bucket", ProviderType: ProviderAWS}) }); err != nil {
+			t.Fatalf("%v: %v", test.statusCode, err)
+		}
+		if status != test.expected {
+			t.Errorf("%v: bucket status is %v, expected %v", test.statusCode, status, test.expected)
+		}
+	}
+}
+
+func TestAWSCreateInaccessibleBucket(t *testing.T) {
+	created := false
+	a := newFakeAWSStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			created = true
+		}
+		w.WriteHeader(http.StatusForbidden)
+	})
+	err := catchErrors(func() { a.createBucket(GoStorageObject{Bucket: "bucket", ProviderType: ProviderAWS}, BucketOptions{}) })
+	if err == nil || created {
+		t.Errorf("bucket owned by another account was created")
+	}
+}
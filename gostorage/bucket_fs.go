@@ -0,0 +1,325 @@
+package gostorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BucketFS is a read-only fs.FS over the objects of a bucket, "/" separated keys are treated as directories. Provider
+// errors are returned as *fs.PathError, with fs.ErrNotExist and fs.ErrPermission for missing and inaccessible objects.
+type BucketFS struct {
+	storage GoStorage
+	bucket  GoStorageObject
+	// root prefix of all keys, empty or ending with "/"
+	root string
+}
+
+var (
+	_ fs.ReadDirFS  = BucketFS{}
+	_ fs.StatFS     = BucketFS{}
+	_ fs.ReadFileFS = BucketFS{}
+)
+
+// FS returns a file system over the objects of bucket. If bucket.Key is set, it is used as root directory.
+func (s GoStorage) FS(bucket GoStorageObject) BucketFS {
+	root := strings.Trim(bucket.Key, "/")
+	if root != "" {
+		root += "/"
+	}
+	bucket.Key = ""
+	return BucketFS{storage: s, bucket: bucket, root: root}
+}
+
+func (b BucketFS) Open(name string) (fs.File, error) {
+	info, err := b.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &bucketDir{fs: b, name: name, info: info}, nil
+	}
+	return &bucketFile{fs: b, info: info, name: name}, nil
+}
+
+func (b BucketFS) Stat(name string) (fs.FileInfo, error) {
+	return b.stat("stat", name)
+}
+
+func (b BucketFS) ReadFile(name string) ([]byte, error) {
+	info, err := b.stat("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	file := &bucketFile{fs: b, info: info, name: name}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// ReadDir returns the entries of the directory sorted by name
+func (b BucketFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := b.prefix(name)
+	var keys, prefixes []string
+	err := catchErrors(func() { keys, prefixes = b.storage.provider(b.bucket).listDirectory(b.object(prefix)) })
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	var entries []fs.DirEntry
+	for _, key := range keys {
+		entryName := strings.TrimPrefix(key, prefix)
+		if entryName != "" && fs.ValidPath(entryName) {
+			entries = append(entries, &bucketDirEntry{fs: b, key: key, name: entryName})
+		}
+	}
+	for _, dirPrefix := range prefixes {
+		entryName := strings.TrimSuffix(strings.TrimPrefix(dirPrefix, prefix), "/")
+		if entryName != "" && fs.ValidPath(entryName) {
+			entries = append(entries, &bucketDirEntry{fs: b, name: entryName, dir: true})
+		}
+	}
+	if len(entries) == 0 && name != "." {
+		info, err := b.stat("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// stat returns the info of the object name or, if there is no such object, of the directory name
+func (b BucketFS) stat(op string, name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return bucketFileInfo{name: ".", dir: true}, nil
+	}
+	object := b.object(b.root + name)
+	provider := b.storage.provider(object)
+	var info ObjectInfo
+	err := catchErrors(func() { info = provider.statObject(object) })
+	if err == nil {
+		return bucketFileInfo{name: path.Base(name), size: info.Size, modTime: info.LastModified}, nil
+	}
+	if httpStatusCode(err) != http.StatusNotFound {
+		return nil, pathError(op, name, err)
+	}
+	var keys, prefixes []string
+	err = catchErrors(func() { keys, prefixes = provider.listDirectory(b.object(b.prefix(name))) })
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	if len(keys) > 0 || len(prefixes) > 0 {
+		return bucketFileInfo{name: path.Base(name), dir: true}, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// prefix returns the key prefix of the directory name
+func (b BucketFS) prefix(name string) string {
+	if name == "." {
+		return b.root
+	}
+	return b.root + name + "/"
+}
+
+// pathError maps provider errors to the errors of the fs package
+func pathError(op string, name string, err error) error {
+	switch httpStatusCode(err) {
+	case http.StatusNotFound:
+		err = fs.ErrNotExist
+	case http.StatusForbidden:
+		err = fs.ErrPermission
+	default:
+		if errors.Is(err, fs.ErrPermission) {
+			err = fs.ErrPermission
+		}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (b BucketFS) object(key string) GoStorageObject {
+	object := b.bucket
+	object.Key = key
+	return object
+}
+
+type bucketFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i bucketFileInfo) Name() string       { return i.name }
+func (i bucketFileInfo) Size() int64        { return i.size }
+func (i bucketFileInfo) ModTime() time.Time { return i.modTime }
+func (i bucketFileInfo) IsDir() bool        { return i.dir }
+func (i bucketFileInfo) Sys() interface{}   { return nil }
+
+func (i bucketFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// bucketFile downloads the object on the first read. Seeking forward skips the data in between, seeking backwards
+// downloads the object again.
+type bucketFile struct {
+	fs     BucketFS
+	info   fs.FileInfo
+	name   string
+	reader io.Reader
+	// offset is the position of the next read, position the position of reader
+	offset   int64
+	position int64
+}
+
+var _ io.ReadSeeker = &bucketFile{}
+
+func (f *bucketFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *bucketFile) Read(p []byte) (int, error) {
+	if f.reader != nil && f.offset < f.position {
+		f.Close()
+		f.reader = nil
+	}
+	if f.reader == nil {
+		err := catchErrors(func() { f.reader = f.fs.storage.DownloadFileAsReader(f.fs.object(f.fs.root + f.name)) })
+		if err != nil {
+			return 0, pathError("read", f.name, err)
+		}
+		f.position = 0
+	}
+	if f.offset > f.position {
+		skipped, err := io.CopyN(io.Discard, f.reader, f.offset-f.position)
+		f.position += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.reader.Read(p)
+	f.position += int64(n)
+	f.offset = f.position
+	return n, err
+}
+
+func (f *bucketFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *bucketFile) Close() error {
+	if closer, ok := f.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type bucketDir struct {
+	fs      BucketFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *bucketDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *bucketDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *bucketDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries, or all remaining entries if n <= 0
+func (d *bucketDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// bucketDirEntry stats the object only if its info is requested
+type bucketDirEntry struct {
+	fs   BucketFS
+	key  string
+	name string
+	dir  bool
+}
+
+func (e *bucketDirEntry) Name() string { return e.name }
+func (e *bucketDirEntry) IsDir() bool  { return e.dir }
+
+func (e *bucketDirEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e *bucketDirEntry) Info() (fs.FileInfo, error) {
+	if e.dir {
+		return bucketFileInfo{name: e.name, dir: true}, nil
+	}
+	var info ObjectInfo
+	err := catchErrors(func() { info = e.fs.storage.provider(e.fs.bucket).statObject(e.fs.object(e.key)) })
+	if err != nil {
+		return nil, pathError("stat", e.name, err)
+	}
+	return bucketFileInfo{name: e.name, size: info.Size, modTime: info.LastModified}, nil
+}
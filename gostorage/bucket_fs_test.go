@@ -0,0 +1,210 @@
+package gostorage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeBucket serves the operations used by BucketFS from memory, keys listed in errors fail with the given error
+type fakeBucket struct {
+	objects map[string]string
+	errors  map[string]error
+}
+
+func (f fakeBucket) middleware(request Request, next Handler) Response {
+	key := request.Object.Key
+	if err, ok := f.errors[key]; ok {
+		checkErr(err, err)
+	}
+	switch request.Operation {
+	case OperationStatObject:
+		content, ok := f.objects[key]
+		if !ok {
+			checkErr(storage.ErrObjectNotExist, storage.ErrObjectNotExist)
+		}
+		return Response{ObjectInfo: ObjectInfo{Key: key, Size: int64(len(content)), LastModified: time.Unix(1600000000, 0)}}
+	case OperationDownloadAsReader:
+		return Response{Reader: bytes.NewReader([]byte(f.objects[key]))}
+	case OperationListDirectory:
+		var keys []string
+		prefixes := map[string]bool{}
+		for objectKey := range f.objects {
+			if !strings.HasPrefix(objectKey, key) {
+				continue
+			}
+			if i := strings.Index(objectKey[len(key):], "/"); i != -1 {
+				prefixes[objectKey[:len(key)+i+1]] = true
+			} else {
+				keys = append(keys, objectKey)
+			}
+		}
+		var prefixList []string
+		for prefix := range prefixes {
+			prefixList = append(prefixList, prefix)
+		}
+		sort.Strings(keys)
+		sort.Strings(prefixList)
+		return Response{Keys: keys, Prefixes: prefixList}
+	}
+	checkErr(errors.New("unexpected operation"), request.Operation)
+	return Response{}
+}
+
+func newFakeBucketFS(bucket fakeBucket, root string) BucketFS {
+	s := GoStorage{Middleware: []Middleware{bucket.middleware}}
+	return s.FS(GoStorageObject{Bucket: "bucket", Key: root, ProviderType: ProviderGoogle})
+}
+
+func TestBucketFS(t *testing.T) {
+	bucket := fakeBucket{objects: map[string]string{
+		"a.txt":            "a",
+		"dir/b.txt":        "bb",
+		"dir/sub/c.txt":    "ccc",
+		"root/d.txt":       "dddd",
+		"root/nested/e.md": "",
+	}}
+	if err := fstest.TestFS(newFakeBucketFS(bucket, ""), "a.txt", "dir/b.txt", "dir/sub/c.txt", "root/nested/e.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(newFakeBucketFS(bucket, "root"), "d.txt", "nested/e.md"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBucketFSErrors(t *testing.T) {
+	bucket := fakeBucket{
+		objects: map[string]string{"secret.txt": "s", "flaky.txt": "f"},
+		errors: map[string]error{
+			"secret.txt": &googleapi.Error{Code: http.StatusForbidden},
+			"flaky.txt":  &googleapi.Error{Code: http.StatusServiceUnavailable},
+		},
+	}
+	fsys := newFakeBucketFS(bucket, "")
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"missing.txt", fs.ErrNotExist},
+		{"secret.txt", fs.ErrPermission},
+		{"flaky.txt", nil},
+	}
+	for _, test := range tests {
+		_, err := fsys.Open(test.name)
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			t.Fatalf("Open(%v) returned %v, expected *fs.PathError", test.name, err)
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("Open(%v) returned %v, expected %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestBucketFSGuards(t *testing.T) {
+	bucket := fakeBucket{objects: map[string]string{"public/a.txt": "a", "private/b.txt": "b"}}
+	s := GoStorage{Guards: &Guards{Rules: []GuardRule{{Deny: []string{"bucket/private/"}}}}, Middleware: []Middleware{bucket.middleware}}
+	fsys := s.FS(GoStorageObject{Bucket: "bucket", ProviderType: ProviderGoogle})
+
+	if _, err := fsys.ReadFile("public/a.txt"); err != nil {
+		t.Errorf("ReadFile(public/a.txt) returned %v", err)
+	}
+	if _, err := fsys.Open("private/b.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Open(private/b.txt) returned %v, expected %v", err, fs.ErrPermission)
+	}
+}
+
+func TestBucketFSHTTP(t *testing.T) {
+	// more than the 512 bytes http.ServeContent sniffs before seeking back
+	readme := strings.Repeat("0123456789", 100)
+	bucket := fakeBucket{objects: map[string]string{"README": readme, "dir/page.html": "<html>page</html>"}}
+	server := httptest.NewServer(http.FileServer(http.FS(newFakeBucketFS(bucket, ""))))
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		rangeValue string
+		status     int
+		body       string
+	}{
+		{"/README", "", http.StatusOK, readme},
+		{"/README", "bytes=10-19", http.StatusPartialContent, readme[10:20]},
+		{"/README", "bytes=-5", http.StatusPartialContent, readme[len(readme)-5:]},
+		{"/dir/page.html", "bytes=6-9", http.StatusPartialContent, "page"},
+		{"/dir/page.html", "", http.StatusOK, "<html>page</html>"},
+		{"/missing", "", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest(http.MethodGet, server.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.rangeValue != "" {
+			request.Header.Set("Range", test.rangeValue)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != test.status {
+			t.Errorf("GET %v (Range %q) returned %v: %s", test.path, test.rangeValue, response.Status, body)
+		} else if test.body != "" && string(body) != test.body {
+			t.Errorf("GET %v (Range %q) returned %q, expected %q", test.path, test.rangeValue, body, test.body)
+		}
+	}
+}
+
+func TestBucketFileSeek(t *testing.T) {
+	bucket := fakeBucket{objects: map[string]string{"a.txt": "0123456789"}}
+	file, err := newFakeBucketFS(bucket, "").Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	seeker := file.(io.ReadSeeker)
+
+	read := func(n int) string {
+		buffer := make([]byte, n)
+		n, _ = io.ReadFull(seeker, buffer)
+		return string(buffer[:n])
+	}
+	steps := []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{0, io.SeekStart, "012"},
+		{2, io.SeekCurrent, "567"},
+		{1, io.SeekStart, "123"},
+		{-2, io.SeekEnd, "89"},
+		{5, io.SeekEnd, ""},
+	}
+	for _, step := range steps {
+		if _, err = seeker.Seek(step.offset, step.whence); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(3); got != step.want {
+			t.Errorf("read after Seek(%v, %v) returned %q, expected %q", step.offset, step.whence, got, step.want)
+		}
+	}
+	if _, err = seeker.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seeking before the start succeeded")
+	}
+}
@@ -0,0 +1,23 @@
+package gostorage
+
+// BucketOptions settings which are applied when a bucket is created. Settings which are not supported by a provider
+// (StorageClass on AWS, ObjectLock on Google) are rejected.
+type BucketOptions struct {
+	// Location overrides the region of the bucket object: AWS location constraint or Google region, dual-region (e.g. NAM4) or multi-region (e.g. EU)
+	Location string
+	// StorageClass default storage class of new objects (Google only)
+	StorageClass string
+	Versioning   bool
+	// Labels Google bucket labels or AWS bucket tags respectively
+	Labels                   map[string]string
+	UniformBucketLevelAccess bool
+	// ObjectLock enables S3 Object Lock (AWS only)
+	ObjectLock bool
+}
+
+// CreateBucketWithOptions creates the bucket with the given options, if the bucket already exists the options are not applied
+func (s GoStorage) CreateBucketWithOptions(storageObject GoStorageObject, options BucketOptions) CreateBucketResult {
+	s, span := s.startSpan("CreateBucketWithOptions", storageObject)
+	defer span.End()
+	return s.provider(storageObject).createBucket(storageObject, options)
+}
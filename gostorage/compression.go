@@ -0,0 +1,182 @@
+package gostorage
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type CompressionAlgorithm string
+
+const (
+	CompressionGzip CompressionAlgorithm = "gzip"
+	CompressionZstd CompressionAlgorithm = "zstd"
+)
+
+// Compression compresses objects on upload and decompresses them on download. The used algorithm is recorded in the
+// metadata of the object, objects without this metadata are downloaded as they are.
+type Compression struct {
+	Algorithm CompressionAlgorithm
+	// KeepCompressedOnCopy copies compressed objects between providers without decompressing and compressing them again
+	KeepCompressedOnCopy bool
+}
+
+// Wrap returns a provider which compresses objects on upload and decompresses them on download
+func (c *Compression) Wrap(provider Provider) Provider {
+	return compressionProvider{Provider: provider, compression: c}
+}
+
+type compressionProvider struct {
+	Provider
+	compression *Compression
+}
+
+func (p compressionProvider) uploadFile(target GoStorageObject, sourceFile string) {
+	source, err := os.Open(sourceFile)
+	checkErr(err, fmt.Sprintf("unable to read file content from %v, Error: %v", sourceFile, err))
+	defer source.Close()
+	tempFile, err := os.CreateTemp(os.TempDir(), "gostorage-compressed")
+	checkErr(err, fmt.Sprintf("unable to create temporary file, Error: %v", err))
+	tempFilePath := getAbsolutePath(tempFile)
+	defer os.Remove(tempFilePath)
+
+	writer, err := newCompressingWriter(tempFile, p.compression.Algorithm)
+	checkErr(err, fmt.Sprintf("unable to compress file %v, Error: %v", sourceFile, err))
+	size, err := io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	checkErr(err, fmt.Sprintf("unable to compress file %v, Error: %v", sourceFile, err))
+	err = tempFile.Close()
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", tempFilePath, err))
+
+	metadata := map[string]string{}
+	for k, v := range target.Metadata {
+		metadata[k] = v
+	}
+	metadata[ContentEncodingMetadataKey] = string(p.compression.Algorithm)
+	metadata[UncompressedSizeMetadataKey] = strconv.FormatInt(size, 10)
+	target.Metadata = metadata
+	p.Provider.uploadFile(target, tempFilePath)
+}
+
+func (p compressionProvider) downloadFile(source GoStorageObject, targetFile string) {
+	reader := p.downloadFileAsReader(source)
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	file, err := os.Create(targetFile)
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", targetFile, err))
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	checkErr(err, fmt.Sprintf("unable to download contents of storage object %v, Error: %v", source.Key, err))
+}
+
+func (p compressionProvider) downloadFileAsReader(source GoStorageObject) io.Reader {
+	info := p.Provider.statObject(source)
+	contentEncoding, ok := info.Metadata[ContentEncodingMetadataKey]
+	if !ok {
+		return p.Provider.downloadFileAsReader(source)
+	}
+	if source.VersionId == "" {
+		source.VersionId = info.VersionId
+	}
+	reader, err := newDecompressingReader(p.Provider.downloadFileAsReader(source), CompressionAlgorithm(contentEncoding))
+	checkErr(err, fmt.Sprintf("unable to decompress storage object %v, Error: %v", source.Key, err))
+	return reader
+}
+
+// statObject returns the uncompressed size for compressed objects
+func (p compressionProvider) statObject(source GoStorageObject) ObjectInfo {
+	info := p.Provider.statObject(source)
+	if uncompressedSize, ok := info.Metadata[UncompressedSizeMetadataKey]; ok {
+		size, err := strconv.ParseInt(uncompressedSize, 10, 64)
+		if err == nil {
+			info.Size = size
+		}
+	}
+	return info
+}
+
+// copyFileWithinProvider keeps the compression metadata of source if the copy replaces the metadata
+func (p compressionProvider) copyFileWithinProvider(source GoStorageObject, target GoStorageObject) {
+	if target.Metadata != nil {
+		target.Metadata = withCompressionMetadata(p.Provider.statObject(source), target.Metadata)
+	}
+	p.Provider.copyFileWithinProvider(source, target)
+}
+
+func (p compressionProvider) copyBucketWithinProvider(source GoStorageObject, target GoStorageObject) {
+	if target.Metadata == nil {
+		p.Provider.copyBucketWithinProvider(source, target)
+		return
+	}
+	for _, fileKey := range p.listFilesInBucket(source) {
+		source.Key = fileKey
+		target.Key = fileKey
+		p.copyFileWithinProvider(source, target)
+	}
+}
+
+// withCompressionMetadata adds the compression related metadata of the object to metadata
+func withCompressionMetadata(info ObjectInfo, metadata map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for _, key := range []string{ContentEncodingMetadataKey, UncompressedSizeMetadataKey} {
+		if value, ok := info.Metadata[key]; ok {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+func newCompressingWriter(writer io.Writer, algorithm CompressionAlgorithm) (io.WriteCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		return gzip.NewWriter(writer), nil
+	case CompressionZstd:
+		return zstd.NewWriter(writer)
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %v", algorithm)
+	}
+}
+
+// decompressingReader closes the decompressor as well as the underlying reader
+type decompressingReader struct {
+	io.Reader
+	source io.Reader
+	close  func()
+}
+
+func newDecompressingReader(reader io.Reader, algorithm CompressionAlgorithm) (*decompressingReader, error) {
+	switch algorithm {
+	case CompressionGzip:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressingReader{Reader: gzipReader, source: reader, close: func() { gzipReader.Close() }}, nil
+	case CompressionZstd:
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressingReader{Reader: zstdReader, source: reader, close: zstdReader.Close}, nil
+	default:
+		return nil, fmt.Errorf("unknown content encoding %v", algorithm)
+	}
+}
+
+func (r *decompressingReader) Close() error {
+	r.close()
+	if closer, ok := r.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package gostorage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCompressionStorage(memory *memoryStorage, compression Compression) GoStorage {
+	return GoStorage{Compression: &compression, Middleware: []Middleware{memory.middleware},
+		CopyOptions: CopyOptions{BucketCreation: BucketCreationNever}}
+}
+
+// download returns the content of object downloaded with s
+func download(t *testing.T, s GoStorage, object GoStorageObject) string {
+	file := filepath.Join(t.TempDir(), "download")
+	s.Copy(object, GoStorageObject{IsLocal: true, LocalFilePath: file})
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func upload(t *testing.T, s GoStorage, object GoStorageObject, content string) {
+	s.Copy(GoStorageObject{IsLocal: true, LocalFilePath: writeTempFile(t, content)}, object)
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	content := strings.Repeat("compressible content ", 1000)
+	for _, algorithm := range []CompressionAlgorithm{CompressionGzip, CompressionZstd} {
+		memory := newMemoryStorage()
+		s := newCompressionStorage(memory, Compression{Algorithm: algorithm})
+		object := GoStorageObject{Bucket: "bucket", Key: "file.txt", ProviderType: ProviderGoogle}
+		upload(t, s, object, content)
+
+		stored := memory.objects[memoryKey(object)]
+		if len(stored.content) >= len(content) || stored.metadata[ContentEncodingMetadataKey] != string(algorithm) {
+			t.Errorf("%v: object is stored with %v bytes and metadata %v", algorithm, len(stored.content), stored.metadata)
+		}
+		if got := download(t, s, object); got != content {
+			t.Errorf("%v: downloaded content differs from the uploaded content", algorithm)
+		}
+		if size := s.GetObjectInfo(object).Size; size != int64(len(content)) {
+			t.Errorf("%v: GetObjectInfo returned size %v, expected %v", algorithm, size, len(content))
+		}
+	}
+}
+
+func TestCompressionCopyWithinProviderKeepsCompressionMetadata(t *testing.T) {
+	content := strings.Repeat("compressible content ", 1000)
+	memory := newMemoryStorage()
+	s := newCompressionStorage(memory, Compression{Algorithm: CompressionGzip})
+	source := GoStorageObject{Bucket: "source", Key: "file.txt", ProviderType: ProviderAWS}
+	upload(t, s, source, content)
+
+	target := GoStorageObject{Bucket: "target", Key: "file.txt", ProviderType: ProviderAWS, Metadata: map[string]string{"owner": "test"}}
+	s.Copy(source, target)
+	if got := download(t, s, target); got != content {
+		t.Error("copied file does not download as the original content")
+	}
+	if owner := memory.objects[memoryKey(target)].metadata["owner"]; owner != "test" {
+		t.Errorf("metadata of the copy was not replaced, owner is %q", owner)
+	}
+
+	bucketTarget := GoStorageObject{Bucket: "bucket-copy", ProviderType: ProviderAWS, Metadata: map[string]string{"owner": "test"}}
+	s.Copy(GoStorageObject{Bucket: "source", ProviderType: ProviderAWS}, bucketTarget)
+	bucketTarget.Key = "file.txt"
+	if got := download(t, s, bucketTarget); got != content {
+		t.Error("file of the copied bucket does not download as the original content")
+	}
+}
+
+func TestCompressionKeepCompressedOnCopy(t *testing.T) {
+	content := strings.Repeat("compressible content ", 1000)
+	source := GoStorageObject{Bucket: "source", Key: "file.txt", ProviderType: ProviderAWS}
+	target := GoStorageObject{Bucket: "target", Key: "file.txt", ProviderType: ProviderGoogle}
+
+	for _, keepCompressed := range []bool{true, false} {
+		memory := newMemoryStorage()
+		s := newCompressionStorage(memory, Compression{Algorithm: CompressionGzip, KeepCompressedOnCopy: keepCompressed})
+		upload(t, s, source, content)
+		// the target is compressed with another algorithm if it is compressed again
+		s.Compression.Algorithm = CompressionZstd
+		s.Copy(source, target)
+
+		stored := memory.objects[memoryKey(target)]
+		sameBytes := bytes.Equal(stored.content, memory.objects[memoryKey(source)].content)
+		if sameBytes != keepCompressed {
+			t.Errorf("KeepCompressedOnCopy %v: target stores the compressed bytes of the source: %v", keepCompressed, sameBytes)
+		}
+		if got := download(t, s, target); got != content {
+			t.Errorf("KeepCompressedOnCopy %v: copied file does not download as the original content", keepCompressed)
+		}
+	}
+}
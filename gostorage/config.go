@@ -0,0 +1,132 @@
+package gostorage
+
+import (
+	"reflect"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	aws_s3 "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Config per instance configuration of GoStorage, so multiple projects/accounts can be used concurrently in one process
+type Config struct {
+	Credentials CredentialsHolder
+	// GoogleProjectId project in which new Google buckets are created, defaults to the project of the Google credentials
+	GoogleProjectId string
+	// DefaultAWSRegion defaults to DefaultAWSRegion
+	DefaultAWSRegion string
+	// DefaultGoogleRegion defaults to DefaultGoogleRegion
+	DefaultGoogleRegion string
+	// AWSEndpoint custom S3 endpoint (e.g. for S3 compatible storage), buckets are addressed path-style if set
+	AWSEndpoint string
+	// GoogleEndpoint custom Google Storage endpoint
+	GoogleEndpoint string
+	// Accounts additional named accounts, referenced by GoStorageObject.Account
+	Accounts map[string]Account
+
+	eventLogger Logger
+}
+
+// Account named set of credentials, e.g. a second AWS account or Google project
+type Account struct {
+	Credentials CredentialsHolder
+	// GoogleProjectId overrides Config.GoogleProjectId for this account
+	GoogleProjectId string
+}
+
+// New creates a GoStorage instance using the given configuration, clients are created once and reused by the instance
+// and buckets known to exist are cached
+func New(config Config) GoStorage {
+	return GoStorage{Credentials: config.Credentials, Config: config, clients: &clientCache{}, knownBuckets: &bucketCache{}}
+}
+
+// Instances which were not created by New (e.g. GoStorage{Credentials: credentials}) share their clients and known
+// buckets with all other such instances using the same credentials and endpoints
+var sharedClients, sharedBuckets sync.Map
+
+type sharedCacheKey struct {
+	credentials    CredentialsHolder
+	awsEndpoint    string
+	googleEndpoint string
+}
+
+// sharedCacheKey returns the key of the shared caches, false if the AWS credentials provider can not be used as key
+func (c Config) sharedCacheKey() (sharedCacheKey, bool) {
+	provider := c.Credentials.AwsCredentialsProvider
+	if provider != nil && !reflect.TypeOf(provider).Comparable() {
+		return sharedCacheKey{}, false
+	}
+	return sharedCacheKey{credentials: c.Credentials, awsEndpoint: c.AWSEndpoint, googleEndpoint: c.GoogleEndpoint}, true
+}
+
+// sharedClientCache returns the shared client cache of the credentials of c, or nil if clients can not be shared
+func (c Config) sharedClientCache() *clientCache {
+	key, ok := c.sharedCacheKey()
+	if !ok {
+		return nil
+	}
+	cache, _ := sharedClients.LoadOrStore(key, &clientCache{})
+	return cache.(*clientCache)
+}
+
+// sharedBucketCache returns the shared bucket cache of the credentials of c, or nil if buckets can not be shared
+func (c Config) sharedBucketCache() *bucketCache {
+	key, ok := c.sharedCacheKey()
+	if !ok {
+		return nil
+	}
+	cache, _ := sharedBuckets.LoadOrStore(key, &bucketCache{})
+	return cache.(*bucketCache)
+}
+
+func (c Config) awsRegion() string {
+	if c.DefaultAWSRegion != "" {
+		return c.DefaultAWSRegion
+	}
+	return DefaultAWSRegion
+}
+
+func (c Config) googleRegion() string {
+	if c.DefaultGoogleRegion != "" {
+		return c.DefaultGoogleRegion
+	}
+	return DefaultGoogleRegion
+}
+
+// clientCache holds the storage clients of a GoStorage instance per account (and region for AWS)
+type clientCache struct {
+	mutex         sync.Mutex
+	googleClients map[string]*storage.Client
+	awsClients    map[string]*aws_s3.Client
+}
+
+func (c *clientCache) getGoogleClient(account string, create func() *storage.Client) *storage.Client {
+	if c == nil {
+		return create()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.googleClients == nil {
+		c.googleClients = map[string]*storage.Client{}
+	}
+	if _, ok := c.googleClients[account]; !ok {
+		c.googleClients[account] = create()
+	}
+	return c.googleClients[account]
+}
+
+func (c *clientCache) getAWSClient(account string, region string, create func() *aws_s3.Client) *aws_s3.Client {
+	if c == nil {
+		return create()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.awsClients == nil {
+		c.awsClients = map[string]*aws_s3.Client{}
+	}
+	cacheKey := account + "/" + region
+	if _, ok := c.awsClients[cacheKey]; !ok {
+		c.awsClients[cacheKey] = create()
+	}
+	return c.awsClients[cacheKey]
+}
@@ -0,0 +1,48 @@
+package gostorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSharedCaches(t *testing.T) {
+	credentials := CredentialsHolder{AwsCredentials: &aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}}
+	other := CredentialsHolder{AwsCredentials: &aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}}
+	if (Config{Credentials: credentials}).sharedClientCache() != (Config{Credentials: credentials}).sharedClientCache() {
+		t.Error("instances with the same credentials use different client caches")
+	}
+	if (Config{Credentials: credentials}).sharedClientCache() == (Config{Credentials: other}).sharedClientCache() {
+		t.Error("instances with different credentials share their client cache")
+	}
+	if (Config{Credentials: credentials, AWSEndpoint: "http://localhost"}).sharedClientCache() == (Config{Credentials: credentials}).sharedClientCache() {
+		t.Error("instances with different endpoints share their client cache")
+	}
+	uncomparable := CredentialsHolder{AwsCredentialsProvider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, nil
+	})}
+	if (Config{Credentials: uncomparable}).sharedClientCache() != nil {
+		t.Error("credentials provider which can not be used as key is shared")
+	}
+
+	object := GoStorageObject{ProviderType: ProviderAWS, Bucket: "bucket"}
+	client := object.getProvider(Config{Credentials: credentials}, nil).(AWSStorage).getClientWithRegion("")
+	if object.getProvider(Config{Credentials: credentials}, nil).(AWSStorage).getClientWithRegion("") != client {
+		t.Error("AWS client is created again for the same credentials")
+	}
+}
+
+func TestStructLiteralInstancesRememberKnownBuckets(t *testing.T) {
+	memory := newMemoryStorage()
+	credentials := CredentialsHolder{AwsCredentials: &aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}}
+	target := GoStorageObject{ProviderType: ProviderAWS, Bucket: "bucket", Key: "file.txt"}
+	for i := 0; i < 2; i++ {
+		s := GoStorage{Credentials: credentials, Middleware: []Middleware{memory.middleware},
+			CopyOptions: CopyOptions{BucketCreation: BucketCreationIfMissing}}
+		upload(t, s, target, "content")
+	}
+	if calls := memory.calls[OperationCreateBucket]; calls != 1 {
+		t.Errorf("bucket was created %v times", calls)
+	}
+}
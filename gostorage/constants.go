@@ -1,5 +1,7 @@
 package gostorage
 
+import "time"
+
 const DefaultAWSRegion = "us-east-1"
 
 const DefaultGoogleRegion = "US"
@@ -10,3 +12,30 @@ const AWSSecretAccessKey = "aws_secret_access_key"
 const AWSSessionTokenKey = "aws_session_token"
 
 const GoogleProjectId = "project_id"
+
+const GoogleStorageScope = "https://www.googleapis.com/auth/devstorage.full_control"
+
+// CredentialsRefreshWindow expiring AWS credentials are refreshed this long before they expire
+const CredentialsRefreshWindow = 5 * time.Minute
+
+//Metadata keys used by the client-side envelope encryption
+const EnvelopeWrappedKeyMetadataKey = "gostorage-wrapped-key"
+const EnvelopeKeyIdMetadataKey = "gostorage-key-id"
+
+//Metadata keys used by the transparent compression
+const ContentEncodingMetadataKey = "gostorage-content-encoding"
+const UncompressedSizeMetadataKey = "gostorage-uncompressed-size"
+
+//Prefix of the metadata keys storing object tags on Google
+const GoogleTagMetadataPrefix = "gostorage-tag-"
+
+//Limits of batch deletes
+const AWSDeleteObjectsBatchSize = 1000
+const GoogleDeleteConcurrency = 16
+
+//Limits of copies on AWS, larger objects are copied in parts
+const AWSMaxCopyObjectSize = 5 << 30
+const AWSCopyPartSize = 512 << 20
+
+// DefaultReadCacheSize maximum size of the local read cache in bytes
+const DefaultReadCacheSize = 1 << 30
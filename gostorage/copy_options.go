@@ -0,0 +1,80 @@
+package gostorage
+
+import "sync"
+
+type BucketCreationMode string
+
+const (
+	// BucketCreationAlways creates the target bucket of every copy if it does not exist (default)
+	BucketCreationAlways BucketCreationMode = ""
+	// BucketCreationIfMissing creates the target bucket if it does not exist, buckets known to exist are not checked again
+	// by the same GoStorage instance
+	BucketCreationIfMissing BucketCreationMode = "if-missing"
+	// BucketCreationNever expects the target bucket to exist
+	BucketCreationNever BucketCreationMode = "never"
+)
+
+type CopyOptions struct {
+	BucketCreation BucketCreationMode
+}
+
+// CopyWithOptions is like Copy but uses the given options instead of GoStorage.CopyOptions
+func (s GoStorage) CopyWithOptions(source GoStorageObject, target GoStorageObject, options CopyOptions) {
+	s.CopyOptions = options
+	s.Copy(source, target)
+}
+
+// ensureBucket creates the target bucket according to the configured BucketCreationMode
+func (s GoStorage) ensureBucket(target GoStorageObject) {
+	switch s.CopyOptions.BucketCreation {
+	case BucketCreationNever:
+		return
+	case BucketCreationIfMissing:
+		if s.bucketCache().contains(target) {
+			return
+		}
+		s.provider(target).createBucket(target, BucketOptions{})
+		s.bucketCache().add(target)
+	default:
+		s.provider(target).createBucket(target, BucketOptions{})
+	}
+}
+
+// bucketCache returns the cache of the buckets known to exist
+func (s GoStorage) bucketCache() *bucketCache {
+	if s.knownBuckets != nil {
+		return s.knownBuckets
+	}
+	config := s.Config
+	config.Credentials = s.Credentials
+	return config.sharedBucketCache()
+}
+
+// bucketCache remembers the buckets which are known to exist
+type bucketCache struct {
+	buckets sync.Map
+}
+
+func (c *bucketCache) contains(bucket GoStorageObject) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.buckets.Load(bucketCacheKey(bucket))
+	return ok
+}
+
+func (c *bucketCache) add(bucket GoStorageObject) {
+	if c != nil {
+		c.buckets.Store(bucketCacheKey(bucket), true)
+	}
+}
+
+func (c *bucketCache) remove(bucket GoStorageObject) {
+	if c != nil {
+		c.buckets.Delete(bucketCacheKey(bucket))
+	}
+}
+
+func bucketCacheKey(bucket GoStorageObject) string {
+	return string(bucket.ProviderType) + "/" + bucket.Account + "/" + bucket.Bucket
+}
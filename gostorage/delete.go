@@ -0,0 +1,70 @@
+package gostorage
+
+// DeleteResult is the result of deleting a single object, Err is nil if the object was deleted
+type DeleteResult struct {
+	Key       string
+	VersionId string
+	Err       error
+}
+
+// DeleteMany deletes the objects (optionally a specific VersionId) in batches, objects of different buckets may be mixed.
+// Unlike DeleteFile failures do not abort the deletion, the result of every object is returned in the order of objects.
+func (s GoStorage) DeleteMany(objects []GoStorageObject) []DeleteResult {
+	s, span := s.startSpan("DeleteMany", GoStorageObject{})
+	defer span.End()
+	results := make([]DeleteResult, len(objects))
+	batches := map[string][]int{}
+	var batchKeys []string
+	for i, object := range objects {
+		batchKey := bucketCacheKey(object) + "/" + object.Region
+		if _, ok := batches[batchKey]; !ok {
+			batchKeys = append(batchKeys, batchKey)
+		}
+		batches[batchKey] = append(batches[batchKey], i)
+	}
+	for _, batchKey := range batchKeys {
+		var batch []GoStorageObject
+		for _, i := range batches[batchKey] {
+			batch = append(batch, objects[i])
+		}
+		batchResults := s.provider(batch[0]).deleteFiles(batch)
+		for j, i := range batches[batchKey] {
+			results[i] = batchResults[j]
+		}
+	}
+	setSpanError(span, firstDeleteError(results))
+	return results
+}
+
+// DeletePrefix deletes all objects in prefix.Bucket whose key starts with prefix.Key
+func (s GoStorage) DeletePrefix(prefix GoStorageObject) []DeleteResult {
+	s, span := s.startSpan("DeletePrefix", prefix)
+	defer span.End()
+	var objects []GoStorageObject
+	for _, info := range s.provider(prefix).listObjects(prefix) {
+		object := prefix
+		object.Key = info.Key
+		object.VersionId = ""
+		objects = append(objects, object)
+	}
+	return s.DeleteMany(objects)
+}
+
+// versionsToDelete returns all versions of the bucket as objects which can be passed to deleteFiles
+func versionsToDelete(bucket GoStorageObject, versions []ObjectVersion) []GoStorageObject {
+	objects := make([]GoStorageObject, 0, len(versions))
+	for _, version := range versions {
+		objects = append(objects, GoStorageObject{Bucket: bucket.Bucket, Key: version.Key, VersionId: version.VersionId, Region: bucket.Region, ProviderType: bucket.ProviderType, Account: bucket.Account})
+	}
+	return objects
+}
+
+// firstDeleteError returns the first error of results or nil if all objects were deleted
+func firstDeleteError(results []DeleteResult) error {
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package gostorage
+
+import (
+	"os"
+)
+
+type PlanAction string
+
+const (
+	PlanActionCreateBucket PlanAction = "create-bucket"
+	PlanActionCopy         PlanAction = "copy"
+	PlanActionOverwrite    PlanAction = "overwrite"
+	PlanActionDelete       PlanAction = "delete"
+	PlanActionDeleteBucket PlanAction = "delete-bucket"
+)
+
+// PlannedAction is a single action of a dry run, Source is only set for copies
+type PlannedAction struct {
+	Action PlanAction
+	Source GoStorageObject
+	Target GoStorageObject
+	Size   int64
+}
+
+// Plan lists the actions an operation would perform
+type Plan struct {
+	Actions []PlannedAction
+}
+
+// TotalSize returns the number of bytes which would be copied or deleted
+func (p Plan) TotalSize() int64 {
+	var size int64
+	for _, action := range p.Actions {
+		size += action.Size
+	}
+	return size
+}
+
+// DryRunDeleteBucket returns the actions DeleteBucket would perform without deleting anything.
+// All versions of all objects are deleted, the plan is empty if the bucket is not empty and deleteIfNotEmpty is false.
+func (s GoStorage) DryRunDeleteBucket(bucket GoStorageObject, deleteIfNotEmpty bool) Plan {
+	s, span := s.startSpan("DryRunDeleteBucket", bucket)
+	defer span.End()
+	plan := Plan{}
+	versions := s.provider(bucket).listObjectVersions(GoStorageObject{Bucket: bucket.Bucket, Region: bucket.Region, ProviderType: bucket.ProviderType, Account: bucket.Account})
+	if len(versions) > 0 && !deleteIfNotEmpty {
+		return plan
+	}
+	for i, object := range versionsToDelete(bucket, versions) {
+		plan.Actions = append(plan.Actions, PlannedAction{Action: PlanActionDelete, Target: object, Size: versions[i].Size})
+	}
+	plan.Actions = append(plan.Actions, PlannedAction{Action: PlanActionDeleteBucket, Target: bucket})
+	return plan
+}
+
+// DryRunCopy returns the actions Copy would perform with the configured CopyOptions without copying anything. The
+// objects of a bucket copy are listed once with their stored sizes instead of being requested one by one. Actions the
+// Guards reject fail like in Copy.
+func (s GoStorage) DryRunCopy(source GoStorageObject, target GoStorageObject) Plan {
+	s, span := s.startSpan("DryRunCopy", source)
+	defer span.End()
+	plan := Plan{}
+
+	if source.IsLocal && !target.IsLocal { //Upload file
+		s.planBucketCreation(target, &plan)
+		s.checkGuards(Request{Operation: OperationUpload, Object: target, LocalFile: source.LocalFilePath})
+		plan.Actions = append(plan.Actions, s.plannedCopy(source, target, fileSize(source.LocalFilePath)))
+
+	} else if !source.IsLocal && target.IsLocal { //Download file
+		s.checkGuards(Request{Operation: OperationDownload, Object: source, LocalFile: target.LocalFilePath})
+		plan.Actions = append(plan.Actions, s.plannedCopy(source, target, s.provider(source).statObject(source).Size))
+
+	} else if !source.IsLocal && !target.IsLocal { //Copy between (possibly different) providers
+		serverSide := source.ProviderType == target.ProviderType && s.isServerSideCopyPermitted(source, target)
+		if source.Key == "" && target.Key == "" {
+			created := s.planBucketCreation(target, &plan)
+			if serverSide {
+				s.checkGuards(Request{Operation: OperationCopyBucket, Object: source, Target: target})
+			} else {
+				s.checkGuards(Request{Operation: OperationListFiles, Object: source})
+			}
+			existing := map[string]bool{}
+			if !created {
+				for _, object := range s.provider(target).listObjects(target) {
+					existing[object.Key] = true
+				}
+			}
+			for _, object := range s.provider(source).listObjects(source) {
+				source.Key, target.Key = object.Key, object.Key
+				if !serverSide {
+					s.checkCopyBetweenProvidersGuards(source, target)
+				}
+				action := PlannedAction{Action: PlanActionCopy, Source: source, Target: target, Size: object.Size}
+				if existing[object.Key] {
+					action.Action = PlanActionOverwrite
+				}
+				plan.Actions = append(plan.Actions, action)
+			}
+		} else if source.Key != "" && target.Key != "" {
+			s.planBucketCreation(target, &plan)
+			if serverSide {
+				s.checkGuards(Request{Operation: OperationCopyFile, Object: source, Target: target})
+			} else {
+				s.checkCopyBetweenProvidersGuards(source, target)
+			}
+			plan.Actions = append(plan.Actions, s.plannedCopy(source, target, s.provider(source).statObject(source).Size))
+		} else {
+			fail(nil, "Incorrect configuration of source and target key found")
+		}
+
+	} else {
+		fail(nil, "Incorrect configuration of source and target location found")
+	}
+	return plan
+}
+
+// planBucketCreation adds the creation of the target bucket if it does not exist and would be created by Copy, the
+// result tells whether the creation was planned
+func (s GoStorage) planBucketCreation(target GoStorageObject, plan *Plan) bool {
+	if s.CopyOptions.BucketCreation == BucketCreationNever || s.bucketCache().contains(target) {
+		return false
+	}
+	if s.provider(target).bucketStatus(target) != BucketNotFound {
+		return false
+	}
+	bucket := GoStorageObject{Bucket: target.Bucket, Region: target.Region, ProviderType: target.ProviderType, Account: target.Account}
+	s.checkGuards(Request{Operation: OperationCreateBucket, Object: bucket})
+	plan.Actions = append(plan.Actions, PlannedAction{Action: PlanActionCreateBucket, Target: bucket})
+	return true
+}
+
+// checkCopyBetweenProvidersGuards checks the download and upload of a copy through a local file
+func (s GoStorage) checkCopyBetweenProvidersGuards(source GoStorageObject, target GoStorageObject) {
+	s.checkGuards(Request{Operation: OperationDownload, Object: source})
+	s.checkGuards(Request{Operation: OperationUpload, Object: target})
+}
+
+// plannedCopy returns an overwrite action if target exists and a copy action otherwise
+func (s GoStorage) plannedCopy(source GoStorageObject, target GoStorageObject, size int64) PlannedAction {
+	action := PlannedAction{Action: PlanActionCopy, Source: source, Target: target, Size: size}
+	if target.IsLocal {
+		if _, err := os.Stat(target.LocalFilePath); err == nil {
+			action.Action = PlanActionOverwrite
+		}
+	} else if s.provider(target).canAccess(target) {
+		action.Action = PlanActionOverwrite
+	}
+	return action
+}
@@ -0,0 +1,64 @@
+package gostorage
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestDryRunCopyBucket(t *testing.T) {
+	memory := newMemoryStorage()
+	s := GoStorage{Middleware: []Middleware{memory.middleware}}
+	for key, content := range map[string]string{"a": "1", "b": "22", "c": "333"} {
+		memory.put(GoStorageObject{ProviderType: ProviderAWS, Bucket: "source", Key: key}, []byte(content), nil)
+	}
+	memory.put(GoStorageObject{ProviderType: ProviderAWS, Bucket: "target", Key: "b"}, []byte("old"), nil)
+
+	plan := s.DryRunCopy(GoStorageObject{ProviderType: ProviderAWS, Bucket: "source"}, GoStorageObject{ProviderType: ProviderAWS, Bucket: "target"})
+	expected := map[string]PlanAction{"a": PlanActionCopy, "b": PlanActionOverwrite, "c": PlanActionCopy}
+	if len(plan.Actions) != len(expected) {
+		t.Fatalf("plan has actions %v", plan.Actions)
+	}
+	for _, action := range plan.Actions {
+		if action.Action != expected[action.Target.Key] {
+			t.Errorf("%v is planned as %v, expected %v", action.Target.Key, action.Action, expected[action.Target.Key])
+		}
+	}
+	if plan.TotalSize() != 6 {
+		t.Errorf("plan copies %v bytes, expected 6", plan.TotalSize())
+	}
+	if memory.calls[OperationStatObject] != 0 || memory.calls[OperationCanAccess] != 0 {
+		t.Errorf("objects were requested one by one: %v", memory.calls)
+	}
+}
+
+func TestDryRunCopyChecksGuards(t *testing.T) {
+	source := GoStorageObject{ProviderType: ProviderAWS, Bucket: "source", Key: "a"}
+	tests := []struct {
+		name   string
+		target GoStorageObject
+		guards Guards
+	}{
+		{"server-side copy", GoStorageObject{ProviderType: ProviderAWS, Bucket: "locked", Key: "a"},
+			Guards{Rules: []GuardRule{{Operations: []Operation{OperationCopyFile}, Deny: []string{"locked"}}}}},
+		{"bucket copy", GoStorageObject{ProviderType: ProviderAWS, Bucket: "locked"},
+			Guards{Rules: []GuardRule{{Operations: []Operation{OperationCopyBucket}, Deny: []string{"locked"}}}}},
+		{"copy between providers", GoStorageObject{ProviderType: ProviderGoogle, Bucket: "locked", Key: "a"},
+			Guards{Rules: []GuardRule{{Operations: []Operation{OperationUpload}, Deny: []string{"locked"}}}}},
+		{"read-only", GoStorageObject{ProviderType: ProviderGoogle, Bucket: "target", Key: "a"}, Guards{ReadOnly: true}},
+	}
+	for _, test := range tests {
+		memory := newMemoryStorage()
+		memory.put(source, []byte("content"), nil)
+		guards := test.guards
+		s := GoStorage{Guards: &guards, Middleware: []Middleware{memory.middleware}}
+		sourceObject := source
+		if test.target.Key == "" {
+			sourceObject.Key = ""
+		}
+		err := catchErrors(func() { s.DryRunCopy(sourceObject, test.target) })
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%v: dry run returned %v, expected the guard violation", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package gostorage
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+)
+
+type EncryptionType string
+
+const (
+	// EncryptionProviderManaged SSE-S3 on AWS, Google-managed keys on Google Storage
+	EncryptionProviderManaged EncryptionType = "provider-managed"
+	// EncryptionKMS SSE-KMS on AWS, customer-managed encryption keys (CMEK) on Google Storage
+	EncryptionKMS EncryptionType = "kms"
+	// EncryptionCustomerKey SSE-C on AWS, customer-supplied encryption keys (CSEK) on Google Storage
+	EncryptionCustomerKey EncryptionType = "customer-key"
+)
+
+// Encryption server-side encryption settings of a GoStorageObject. When reading (download, source of a copy) only
+// the CustomerKey is used for decryption, when writing (upload, target of a copy) the settings are used for encryption.
+type Encryption struct {
+	Type EncryptionType
+	// KMSKeyId AWS KMS key ID/ARN or Google Cloud KMS key name, used with EncryptionKMS
+	KMSKeyId string
+	// CustomerKey 256-bit AES key, used with EncryptionCustomerKey
+	CustomerKey []byte
+}
+
+// SetDefaultBucketEncryption configures the encryption applied to all new objects of the bucket without explicit encryption settings
+func (s GoStorage) SetDefaultBucketEncryption(bucket GoStorageObject, encryption Encryption) {
+	s, span := s.startSpan("SetDefaultBucketEncryption", bucket)
+	defer span.End()
+	if encryption.Type == EncryptionCustomerKey {
+		fail(nil, fmt.Sprintf("unable to set default encryption of bucket %v, customer supplied keys can not be used as default encryption", bucket.Bucket))
+	}
+	s.provider(bucket).setDefaultBucketEncryption(bucket, encryption)
+}
+
+// usesCustomerKey checks whether encryption is set and requires a customer supplied key
+func (e *Encryption) usesCustomerKey() bool {
+	if e == nil || e.Type != EncryptionCustomerKey {
+		return false
+	}
+	if len(e.CustomerKey) != 32 {
+		fail(nil, fmt.Sprintf("invalid customer encryption key, expected 32 bytes but got %v", len(e.CustomerKey)))
+	}
+	return true
+}
+
+// customerKeyHeaders returns the base64 encoded customer key and its MD5 digest, as required by AWS
+func (e *Encryption) customerKeyHeaders() (string, string) {
+	keyMD5 := md5.Sum(e.CustomerKey)
+	return base64.StdEncoding.EncodeToString(e.CustomerKey), base64.StdEncoding.EncodeToString(keyMD5[:])
+}
@@ -0,0 +1,390 @@
+package gostorage
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// envelopeSegmentSize size of the plaintext segments which are encrypted separately, so objects can be en-/decrypted as stream
+const envelopeSegmentSize = 64 * 1024
+
+const envelopeNoncePrefixSize = 8
+
+// KeyEncryptionKey wraps and unwraps the per-object data keys, e.g. by using a key management service
+type KeyEncryptionKey interface {
+	// ID identifies the key, it is stored along with the wrapped data key to find the key again for unwrapping
+	ID() string
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// AESKeyEncryptionKey KeyEncryptionKey using a locally held 256-bit AES key
+type AESKeyEncryptionKey struct {
+	KeyId string
+	Key   []byte
+}
+
+func (k AESKeyEncryptionKey) ID() string {
+	return k.KeyId
+}
+
+func (k AESKeyEncryptionKey) WrapKey(dataKey []byte) ([]byte, error) {
+	aead, err := newAESGCM(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(k.KeyId)), nil
+}
+
+func (k AESKeyEncryptionKey) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	aead, err := newAESGCM(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	return aead.Open(nil, wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():], []byte(k.KeyId))
+}
+
+// EnvelopeEncryption client-side encryption of object contents with AES-GCM. Every object is encrypted with its own data key,
+// which is wrapped by the KeyEncryptionKey and stored in the metadata of the object.
+type EnvelopeEncryption struct {
+	KeyEncryptionKey KeyEncryptionKey
+	// PreviousKeyEncryptionKeys are only used to unwrap data keys of objects which were not rotated to KeyEncryptionKey yet
+	PreviousKeyEncryptionKeys []KeyEncryptionKey
+}
+
+// Wrap returns a provider which encrypts objects on upload and decrypts them on download
+func (e *EnvelopeEncryption) Wrap(provider Provider) Provider {
+	return envelopeEncryptionProvider{Provider: provider, encryption: e}
+}
+
+// RotateClientSideEncryptionKey re-wraps the data key of target (or all objects in target.Bucket if no key is set)
+// with the current KeyEncryptionKey, the object contents are not re-encrypted
+func (s GoStorage) RotateClientSideEncryptionKey(target GoStorageObject) {
+	s, span := s.startSpan("RotateClientSideEncryptionKey", target)
+	defer span.End()
+	if s.ClientSideEncryption == nil {
+		fail(nil, "unable to rotate keys, client-side encryption is not configured")
+	}
+	provider := envelopeEncryptionProvider{Provider: s.baseProvider(target), encryption: s.ClientSideEncryption}
+	if target.Key != "" {
+		provider.rewrapDataKey(target)
+		return
+	}
+	for _, key := range provider.listFilesInBucket(target) {
+		target.Key = key
+		provider.rewrapDataKey(target)
+	}
+}
+
+func (e *EnvelopeEncryption) keyEncryptionKey(id string) KeyEncryptionKey {
+	for _, kek := range append([]KeyEncryptionKey{e.KeyEncryptionKey}, e.PreviousKeyEncryptionKeys...) {
+		if kek.ID() == id {
+			return kek
+		}
+	}
+	fail(nil, fmt.Sprintf("unable to find key encryption key %v", id))
+	return nil
+}
+
+type envelopeEncryptionProvider struct {
+	Provider
+	encryption *EnvelopeEncryption
+}
+
+func (p envelopeEncryptionProvider) uploadFile(target GoStorageObject, sourceFile string) {
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	checkErr(err, fmt.Sprintf("unable to generate data key, Error: %v", err))
+	wrappedKey, err := p.encryption.KeyEncryptionKey.WrapKey(dataKey)
+	checkErr(err, fmt.Sprintf("unable to wrap data key, Error: %v", err))
+
+	source, err := os.Open(sourceFile)
+	checkErr(err, fmt.Sprintf("unable to read file content from %v, Error: %v", sourceFile, err))
+	defer source.Close()
+	tempFile, err := os.CreateTemp(os.TempDir(), "gostorage-encrypted")
+	checkErr(err, fmt.Sprintf("unable to create temporary file, Error: %v", err))
+	tempFilePath := getAbsolutePath(tempFile)
+	defer os.Remove(tempFilePath)
+
+	writer, err := newEncryptingWriter(tempFile, dataKey)
+	checkErr(err, fmt.Sprintf("unable to encrypt file %v, Error: %v", sourceFile, err))
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	checkErr(err, fmt.Sprintf("unable to encrypt file %v, Error: %v", sourceFile, err))
+	err = tempFile.Close()
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", tempFilePath, err))
+
+	metadata := map[string]string{}
+	for k, v := range target.Metadata {
+		metadata[k] = v
+	}
+	metadata[EnvelopeWrappedKeyMetadataKey] = base64.StdEncoding.EncodeToString(wrappedKey)
+	metadata[EnvelopeKeyIdMetadataKey] = p.encryption.KeyEncryptionKey.ID()
+	target.Metadata = metadata
+	p.Provider.uploadFile(target, tempFilePath)
+}
+
+func (p envelopeEncryptionProvider) downloadFile(source GoStorageObject, targetFile string) {
+	reader := p.downloadFileAsReader(source)
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	file, err := os.Create(targetFile)
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", targetFile, err))
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	checkErr(err, fmt.Sprintf("unable to download contents of storage object %v, Error: %v", source.Key, err))
+}
+
+// downloadFileAsReader returns a reader decrypting the object, objects without a wrapped data key are returned as they are
+func (p envelopeEncryptionProvider) downloadFileAsReader(source GoStorageObject) io.Reader {
+	info := p.Provider.statObject(source)
+	if _, ok := info.Metadata[EnvelopeWrappedKeyMetadataKey]; !ok {
+		return p.Provider.downloadFileAsReader(source)
+	}
+	dataKey := p.unwrapDataKey(source, info.Metadata)
+	if source.VersionId == "" {
+		source.VersionId = info.VersionId
+	}
+	reader, err := newDecryptingReader(p.Provider.downloadFileAsReader(source), dataKey)
+	checkErr(err, fmt.Sprintf("unable to decrypt storage object %v, Error: %v", source.Key, err))
+	return reader
+}
+
+// statObject returns the size of the plaintext for encrypted objects
+func (p envelopeEncryptionProvider) statObject(source GoStorageObject) ObjectInfo {
+	info := p.Provider.statObject(source)
+	if _, ok := info.Metadata[EnvelopeWrappedKeyMetadataKey]; ok {
+		info.Size = envelopePlaintextSize(info.Size)
+	}
+	return info
+}
+
+// setObjectMetadata keeps the wrapped data key when replacing the metadata
+func (p envelopeEncryptionProvider) setObjectMetadata(target GoStorageObject) {
+	target.Metadata = p.withEnvelopeMetadata(target, target.Metadata)
+	p.Provider.setObjectMetadata(target)
+}
+
+func (p envelopeEncryptionProvider) copyFileWithinProvider(source GoStorageObject, target GoStorageObject) {
+	if target.Metadata != nil {
+		target.Metadata = p.withEnvelopeMetadata(source, target.Metadata)
+	}
+	p.Provider.copyFileWithinProvider(source, target)
+}
+
+func (p envelopeEncryptionProvider) copyBucketWithinProvider(source GoStorageObject, target GoStorageObject) {
+	for _, fileKey := range p.listFilesInBucket(source) {
+		source.Key = fileKey
+		target.Key = fileKey
+		p.copyFileWithinProvider(source, target)
+	}
+}
+
+func (p envelopeEncryptionProvider) rewrapDataKey(target GoStorageObject) {
+	info := p.Provider.statObject(target)
+	if _, ok := info.Metadata[EnvelopeWrappedKeyMetadataKey]; !ok {
+		return
+	}
+	if info.Metadata[EnvelopeKeyIdMetadataKey] == p.encryption.KeyEncryptionKey.ID() {
+		return
+	}
+	wrappedKey, err := p.encryption.KeyEncryptionKey.WrapKey(p.unwrapDataKey(target, info.Metadata))
+	checkErr(err, fmt.Sprintf("unable to wrap data key, Error: %v", err))
+
+	info.Metadata[EnvelopeWrappedKeyMetadataKey] = base64.StdEncoding.EncodeToString(wrappedKey)
+	info.Metadata[EnvelopeKeyIdMetadataKey] = p.encryption.KeyEncryptionKey.ID()
+	target.Metadata = info.Metadata
+	p.Provider.setObjectMetadata(target)
+}
+
+func (p envelopeEncryptionProvider) unwrapDataKey(source GoStorageObject, metadata map[string]string) []byte {
+	wrappedKey, err := base64.StdEncoding.DecodeString(metadata[EnvelopeWrappedKeyMetadataKey])
+	checkErr(err, fmt.Sprintf("unable to read wrapped data key of storage object %v, Error: %v", source.Key, err))
+	dataKey, err := p.encryption.keyEncryptionKey(metadata[EnvelopeKeyIdMetadataKey]).UnwrapKey(wrappedKey)
+	checkErr(err, fmt.Sprintf("unable to unwrap data key of storage object %v, Error: %v", source.Key, err))
+	return dataKey
+}
+
+// withEnvelopeMetadata adds the wrapped data key of source to metadata
+func (p envelopeEncryptionProvider) withEnvelopeMetadata(source GoStorageObject, metadata map[string]string) map[string]string {
+	current := p.Provider.statObject(source).Metadata
+	if _, ok := current[EnvelopeWrappedKeyMetadataKey]; !ok {
+		return metadata
+	}
+	merged := map[string]string{}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	merged[EnvelopeWrappedKeyMetadataKey] = current[EnvelopeWrappedKeyMetadataKey]
+	merged[EnvelopeKeyIdMetadataKey] = current[EnvelopeKeyIdMetadataKey]
+	return merged
+}
+
+// envelopePlaintextSize calculates the size of the plaintext from the size of the encrypted stream
+func envelopePlaintextSize(ciphertextSize int64) int64 {
+	encryptedSegmentSize := int64(envelopeSegmentSize + 16)
+	size := ciphertextSize - envelopeNoncePrefixSize
+	segments := (size + encryptedSegmentSize - 1) / encryptedSegmentSize
+	return size - segments*16
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptingWriter encrypts the written data in segments of envelopeSegmentSize. Every segment uses its own nonce
+// (random prefix + counter) and the last segment is marked as such, so reordering or truncation is detected.
+type encryptingWriter struct {
+	writer      io.Writer
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint32
+	buffer      []byte
+}
+
+func newEncryptingWriter(writer io.Writer, dataKey []byte) (*encryptingWriter, error) {
+	aead, err := newAESGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	noncePrefix := make([]byte, envelopeNoncePrefixSize)
+	if _, err = rand.Read(noncePrefix); err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(noncePrefix); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{writer: writer, aead: aead, noncePrefix: noncePrefix}, nil
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	for len(w.buffer) > envelopeSegmentSize {
+		if err := w.sealSegment(w.buffer[:envelopeSegmentSize], false); err != nil {
+			return 0, err
+		}
+		w.buffer = append(w.buffer[:0], w.buffer[envelopeSegmentSize:]...)
+	}
+	return len(p), nil
+}
+
+// Close encrypts the remaining data as last segment, it does not close the underlying writer
+func (w *encryptingWriter) Close() error {
+	return w.sealSegment(w.buffer, true)
+}
+
+func (w *encryptingWriter) sealSegment(plaintext []byte, last bool) error {
+	nonce := segmentNonce(w.noncePrefix, w.counter)
+	w.counter++
+	_, err := w.writer.Write(w.aead.Seal(nil, nonce, plaintext, segmentAdditionalData(last)))
+	return err
+}
+
+type decryptingReader struct {
+	reader      *bufio.Reader
+	source      io.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint32
+	plaintext   []byte
+	done        bool
+}
+
+func newDecryptingReader(reader io.Reader, dataKey []byte) (*decryptingReader, error) {
+	aead, err := newAESGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{reader: bufio.NewReader(reader), source: reader, aead: aead}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) Close() error {
+	if closer, ok := r.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (r *decryptingReader) openSegment() error {
+	if r.noncePrefix == nil {
+		r.noncePrefix = make([]byte, envelopeNoncePrefixSize)
+		if _, err := io.ReadFull(r.reader, r.noncePrefix); err != nil {
+			return fmt.Errorf("encrypted stream is truncated: %v", err)
+		}
+	}
+
+	segment := make([]byte, envelopeSegmentSize+r.aead.Overhead())
+	n, err := io.ReadFull(r.reader, segment)
+	last := false
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		last = true
+	} else if err != nil {
+		return err
+	} else if _, err = r.reader.Peek(1); err == io.EOF {
+		last = true
+	} else if err != nil {
+		return err
+	}
+	if n < r.aead.Overhead() {
+		return errors.New("encrypted stream is truncated")
+	}
+
+	plaintext, err := r.aead.Open(nil, segmentNonce(r.noncePrefix, r.counter), segment[:n], segmentAdditionalData(last))
+	if err != nil {
+		return fmt.Errorf("unable to decrypt segment %v: %v", r.counter, err)
+	}
+	r.counter++
+	r.plaintext = plaintext
+	r.done = last
+	return nil
+}
+
+func segmentNonce(noncePrefix []byte, counter uint32) []byte {
+	nonce := make([]byte, envelopeNoncePrefixSize+4)
+	copy(nonce, noncePrefix)
+	binary.BigEndian.PutUint32(nonce[envelopeNoncePrefixSize:], counter)
+	return nonce
+}
+
+func segmentAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,168 @@
+package gostorage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func newTestKeyEncryptionKey(t *testing.T) AESKeyEncryptionKey {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return AESKeyEncryptionKey{KeyId: "test", Key: key}
+}
+
+// encryptEnvelope encrypts plaintext with a data key that went through wrapping and unwrapping by kek
+func encryptEnvelope(t *testing.T, kek KeyEncryptionKey, plaintext []byte) ([]byte, []byte) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	wrappedKey, err := kek.WrapKey(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrappedKey, err := kek.UnwrapKey(wrappedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unwrappedKey, dataKey) {
+		t.Fatal("unwrapped data key differs from the original data key")
+	}
+
+	ciphertext := &bytes.Buffer{}
+	writer, err := newEncryptingWriter(ciphertext, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext.Bytes(), unwrappedKey
+}
+
+func decryptEnvelope(ciphertext []byte, dataKey []byte) ([]byte, error) {
+	reader, err := newDecryptingReader(bytes.NewReader(ciphertext), dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEnvelopeEncryptionRoundTrip(t *testing.T) {
+	kek := newTestKeyEncryptionKey(t)
+	sizes := []int{0, 1, envelopeSegmentSize - 1, envelopeSegmentSize, envelopeSegmentSize + 1, 3*envelopeSegmentSize + 17}
+	for _, size := range sizes {
+		plaintext := randomBytes(t, size)
+		ciphertext, dataKey := encryptEnvelope(t, kek, plaintext)
+
+		decrypted, err := decryptEnvelope(ciphertext, dataKey)
+		if err != nil {
+			t.Fatalf("size %v: unable to decrypt: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("size %v: decrypted data differs from the plaintext", size)
+		}
+		if got := envelopePlaintextSize(int64(len(ciphertext))); got != int64(size) {
+			t.Errorf("size %v: envelopePlaintextSize(%v) = %v", size, len(ciphertext), got)
+		}
+	}
+}
+
+func TestEnvelopeEncryptionWrongKey(t *testing.T) {
+	kek := newTestKeyEncryptionKey(t)
+	ciphertext, _ := encryptEnvelope(t, kek, randomBytes(t, 100))
+	if _, err := decryptEnvelope(ciphertext, randomBytes(t, 32)); err == nil {
+		t.Error("decrypting with a different data key succeeded")
+	}
+
+	wrappedKey, err := kek.WrapKey(randomBytes(t, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newTestKeyEncryptionKey(t).UnwrapKey(wrappedKey); err == nil {
+		t.Error("unwrapping with a different key encryption key succeeded")
+	}
+}
+
+func TestEnvelopeEncryptionTruncation(t *testing.T) {
+	kek := newTestKeyEncryptionKey(t)
+	ciphertext, dataKey := encryptEnvelope(t, kek, randomBytes(t, 2*envelopeSegmentSize+100))
+	encryptedSegmentSize := envelopeSegmentSize + 16
+
+	tests := []struct {
+		name   string
+		length int
+	}{
+		{"empty", 0},
+		{"nonce prefix only", envelopeNoncePrefixSize},
+		{"last segment removed", envelopeNoncePrefixSize + 2*encryptedSegmentSize},
+		{"last two segments removed", envelopeNoncePrefixSize + encryptedSegmentSize},
+		{"last byte removed", len(ciphertext) - 1},
+		{"cut within a segment", envelopeNoncePrefixSize + encryptedSegmentSize/2},
+	}
+	for _, test := range tests {
+		if _, err := decryptEnvelope(ciphertext[:test.length], dataKey); err == nil {
+			t.Errorf("%v: decrypting the truncated stream succeeded", test.name)
+		}
+	}
+}
+
+func TestEnvelopeEncryptionReorder(t *testing.T) {
+	kek := newTestKeyEncryptionKey(t)
+	ciphertext, dataKey := encryptEnvelope(t, kek, randomBytes(t, 3*envelopeSegmentSize))
+	encryptedSegmentSize := envelopeSegmentSize + 16
+	segment := func(i int) []byte {
+		start := envelopeNoncePrefixSize + i*encryptedSegmentSize
+		return ciphertext[start : start+encryptedSegmentSize]
+	}
+
+	tests := []struct {
+		name  string
+		order []int
+	}{
+		{"first segments swapped", []int{1, 0, 2}},
+		{"last segment moved", []int{0, 2, 1}},
+		{"segment duplicated", []int{0, 0, 2}},
+	}
+	for _, test := range tests {
+		reordered := append([]byte{}, ciphertext[:envelopeNoncePrefixSize]...)
+		for _, i := range test.order {
+			reordered = append(reordered, segment(i)...)
+		}
+		if _, err := decryptEnvelope(reordered, dataKey); err == nil {
+			t.Errorf("%v: decrypting the reordered stream succeeded", test.name)
+		}
+	}
+}
+
+func TestEnvelopePlaintextSize(t *testing.T) {
+	overhead := int64(envelopeNoncePrefixSize + 16)
+	tests := []struct {
+		ciphertextSize int64
+		want           int64
+	}{
+		{overhead, 0},
+		{overhead + envelopeSegmentSize, envelopeSegmentSize},
+		{2*overhead - envelopeNoncePrefixSize + envelopeSegmentSize + 1, envelopeSegmentSize + 1},
+	}
+	for _, test := range tests {
+		if got := envelopePlaintextSize(test.ciphertextSize); got != test.want {
+			t.Errorf("envelopePlaintextSize(%v) = %v, expected %v", test.ciphertextSize, got, test.want)
+		}
+	}
+}
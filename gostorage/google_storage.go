@@ -6,82 +6,147 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
+	"cloud.google.com/go/iam"
 	"cloud.google.com/go/storage"
-	"github.com/spf13/viper"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 type GoogleStorage struct {
 	CredentialsHolder CredentialsHolder
+
+	config  Config
+	clients *clientCache
+	account string
 }
 
-var client *storage.Client
+func (g GoogleStorage) createBucket(target GoStorageObject, options BucketOptions) CreateBucketResult {
+	if options.ObjectLock {
+		fail(nil, fmt.Sprintf("unable to create bucket %v, object lock is not supported for Google Storage", target.Bucket))
+	}
+	switch g.bucketStatus(GoStorageObject{Bucket: target.Bucket}) {
+	case BucketAccessible:
+		return BucketAlreadyExists
+	case BucketInaccessible:
+		fail(nil, fmt.Sprintf("unable to create bucket %v, it exists on GCP but is not accessible, it is probably owned by "+
+			"another project", target.Bucket))
+	}
+	bucketAttrs := &storage.BucketAttrs{
+		Location:                 g.config.googleRegion(),
+		StorageClass:             options.StorageClass,
+		VersioningEnabled:        options.Versioning,
+		Labels:                   options.Labels,
+		UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: options.UniformBucketLevelAccess},
+	}
+	if options.Location != "" {
+		bucketAttrs.Location = options.Location
+	} else if target.Region != "" {
+		bucketAttrs.Location = target.Region
+	}
+	err := g.getClient().Bucket(target.Bucket).Create(context.Background(), g.projectId(), bucketAttrs)
+	checkErr(err, fmt.Sprintf("unable to create bucket on GCP, Error %v", err))
+	return BucketCreated
+}
 
-func (g GoogleStorage) createBucket(bucketName string, region string) {
-	bucketHandle := g.getClient().Bucket(bucketName)
-	_, err := bucketHandle.Attrs(context.Background())
-	if err != nil && err == storage.ErrBucketNotExist {
-		//shared.Log(shared.ProviderGoogle, fmt.Sprintf("Bucket %v doesn't exist, creating new one", shared.ArchiveBucketName))
-		bucketLocationAttr := &storage.BucketAttrs{Location: DefaultGoogleRegion}
-		if region != "" {
-			bucketLocationAttr.Location = region
+// bucketStatus checks whether the bucket exists and is accessible, buckets which exist but are owned by another project
+// are inaccessible
+func (g GoogleStorage) bucketStatus(target GoStorageObject) BucketStatus {
+	_, err := g.getClient().Bucket(target.Bucket).Attrs(context.Background())
+	if err == storage.ErrBucketNotExist {
+		return BucketNotFound
+	}
+	if httpStatusCode(err) == http.StatusForbidden {
+		return BucketInaccessible
+	}
+	checkErr(err, fmt.Sprintf("unable to access bucket on GCP, Error %v", err))
+	return BucketAccessible
+}
+
+func (g GoogleStorage) listBuckets() []BucketInfo {
+	var buckets []BucketInfo
+	bucketIterator := g.getClient().Buckets(context.Background(), g.projectId())
+	for {
+		item, err := bucketIterator.Next()
+		if err == iterator.Done {
+			break
 		}
-		err = bucketHandle.Create(context.Background(), viper.GetString(GoogleProjectId), bucketLocationAttr)
-		checkErr(err, fmt.Sprintf("unable to create bucket on GCP, Error %v", err))
-	} else {
-		checkErr(err, fmt.Sprintf("unable to access bucket on GCP, Error %v", err))
+		checkErr(err, fmt.Sprintf("unable to list buckets on GCP, Error: %v", err))
+		buckets = append(buckets, BucketInfo{Name: item.Name, Region: item.Location, CreationTime: item.Created, ProviderType: ProviderGoogle})
 	}
+	return buckets
 }
 
-func (g GoogleStorage) deleteBucket(target GoStorageObject, deleteIfNotEmpty bool) {
-	filesInBucket := g.listFilesInBucket(target)
-	storageClient := g.getClient()
-	bucket := storageClient.Bucket(target.Bucket)
+func (g GoogleStorage) projectId() string {
+	if g.config.GoogleProjectId != "" {
+		return g.config.GoogleProjectId
+	}
+	return g.CredentialsHolder.GoogleCredentials.ProjectID
+}
 
-	if len(filesInBucket) > 0 && !deleteIfNotEmpty {
-		fmt.Printf("bucket %v is not empty and should not be deleted, if you want to delete a bucket with its contents set deleteIfNotEmpty=true", target.Bucket)
+func (g GoogleStorage) deleteBucket(target GoStorageObject, deleteIfNotEmpty bool) {
+	versions := g.listObjectVersions(GoStorageObject{Bucket: target.Bucket, Region: target.Region})
+	if len(versions) > 0 && !deleteIfNotEmpty {
+		g.config.logger().Warn("bucket is not empty and is not deleted, set deleteIfNotEmpty=true to delete a bucket with its contents", "bucket", target.Bucket)
 		return
 	}
-	for _, key := range filesInBucket {
-		g.deleteFile(GoStorageObject{Bucket: target.Bucket, Key: key, Region: target.Region})
-	}
-	err := bucket.Delete(context.Background())
+
+	err := firstDeleteError(g.deleteFiles(versionsToDelete(target, versions)))
+	checkErr(err, fmt.Sprintf("unable to delete contents of bucket %v on GCP, Error %v", target.Bucket, err))
+	err = g.getClient().Bucket(target.Bucket).Delete(context.Background())
 	checkErr(err, fmt.Sprintf("unable to delete bucket on GCP, Error %v", err))
 }
 
 func (g GoogleStorage) uploadFile(target GoStorageObject, sourceFile string) {
 	_, err := os.Stat(sourceFile)
 	if errors.Is(err, os.ErrNotExist) {
-		fmt.Fprintln(os.Stderr, "Error:", fmt.Sprintf("file %v does not exist", sourceFile))
-		os.Exit(1)
+		fail(nil, fmt.Sprintf("file %v does not exist", sourceFile))
 	}
 	file, err := ioutil.ReadFile(sourceFile)
 	checkErr(err, fmt.Sprintf("unable to read file content from %v, Error: %v", sourceFile, err))
 
-	writer := g.getClient().Bucket(target.Bucket).Object(target.Key).NewWriter(context.Background())
-	defer writer.Close()
+	writer := g.objectHandle(GoStorageObject{Bucket: target.Bucket, Key: target.Key, Encryption: target.Encryption}).NewWriter(context.Background())
+	if target.Metadata != nil {
+		writer.Metadata = target.Metadata
+	}
+	if target.Tags != nil {
+		writer.Metadata = withGoogleTags(target.Metadata, target.Tags)
+	}
+	if target.Encryption != nil && target.Encryption.Type == EncryptionKMS {
+		writer.KMSKeyName = target.Encryption.KMSKeyId
+	}
+	if target.ACL != "" {
+		writer.PredefinedACL = target.ACL.googlePredefinedACL()
+	}
+	// the upload is only finished by Close, so errors of the request (e.g. rejected keys or ACLs) are returned by Close
 	_, err = writer.Write(file)
+	if err == nil {
+		err = writer.Close()
+	}
 	checkErr(err, fmt.Sprintf("unable to write to google storage, Error: %v", err))
 }
 
 func (g GoogleStorage) downloadFileAsReader(source GoStorageObject) io.Reader {
-	reader, err := g.getClient().Bucket(source.Bucket).Object(source.Key).NewReader(context.Background())
+	reader, err := g.objectHandle(source).NewReader(context.Background())
 	checkErr(err, fmt.Sprintf("unable to read from google storage object %v, Error: %v", source.Key, err))
 	return reader
 }
 
 func (g GoogleStorage) downloadFile(source GoStorageObject, targetFile string) {
-	reader, err := g.getClient().Bucket(source.Bucket).Object(source.Key).NewReader(context.Background())
+	reader, err := g.objectHandle(source).NewReader(context.Background())
 	checkErr(err, fmt.Sprintf("unable to read from google storage object %v, Error: %v", source.Key, err))
 	defer reader.Close()
 
 	data, err := ioutil.ReadAll(reader)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", fmt.Sprintf("unable to download contents google storage object, Error: %v", err))
-		os.Exit(1)
+		fail(err, fmt.Sprintf("unable to download contents google storage object, Error: %v", err))
 	} else {
 		err = ioutil.WriteFile(targetFile, data, 0)
 		checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", targetFile, err))
@@ -102,16 +167,137 @@ func (g GoogleStorage) listFilesInBucket(source GoStorageObject) []string {
 	return keys
 }
 
+// listDirectory lists the keys and common prefixes directly below the prefix source.Key, using "/" as delimiter
+func (g GoogleStorage) listDirectory(source GoStorageObject) ([]string, []string) {
+	var keys, prefixes []string
+	objectIterator := g.getClient().Bucket(source.Bucket).Objects(context.Background(), &storage.Query{Prefix: source.Key, Delimiter: "/"})
+	for {
+		item, err := objectIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		checkErr(err, fmt.Sprintf("unable to list files from bucket %v, Error: %v", source.Bucket, err))
+		if item.Prefix != "" {
+			prefixes = append(prefixes, item.Prefix)
+		} else {
+			keys = append(keys, item.Name)
+		}
+	}
+	return keys, prefixes
+}
+
+// listObjects lists all objects whose key starts with source.Key
+func (g GoogleStorage) listObjects(source GoStorageObject) []ObjectInfo {
+	var objects []ObjectInfo
+	objectIterator := g.getClient().Bucket(source.Bucket).Objects(context.Background(), &storage.Query{Prefix: source.Key})
+	for {
+		item, err := objectIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		checkErr(err, fmt.Sprintf("unable to list files from bucket %v, Error: %v", source.Bucket, err))
+		objects = append(objects, ObjectInfo{
+			Key:          item.Name,
+			VersionId:    strconv.FormatInt(item.Generation, 10),
+			Size:         item.Size,
+			ETag:         item.Etag,
+			LastModified: item.Updated,
+			Metadata:     item.Metadata,
+		})
+	}
+	return objects
+}
+
 func (g GoogleStorage) deleteFile(target GoStorageObject) {
-	err := g.getClient().Bucket(target.Bucket).Object(target.Key).Delete(context.Background())
+	err := g.objectHandle(target).Delete(context.Background())
 	checkErr(err, fmt.Sprintf("unable to delete file %v, Error: %v", target.Key, err))
 }
 
+// deleteFiles deletes the objects concurrently with up to GoogleDeleteConcurrency requests at a time
+func (g GoogleStorage) deleteFiles(targets []GoStorageObject) []DeleteResult {
+	results := make([]DeleteResult, len(targets))
+	semaphore := make(chan struct{}, GoogleDeleteConcurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i] = DeleteResult{Key: target.Key, VersionId: target.VersionId}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, target GoStorageObject) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if err := g.objectHandle(target).Delete(context.Background()); err != nil {
+				results[i].Err = fmt.Errorf("unable to delete file %v, Error: %v", target.Key, err)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+func (g GoogleStorage) statObject(source GoStorageObject) ObjectInfo {
+	attrs, err := g.objectHandle(source).Attrs(context.Background())
+	checkErr(err, fmt.Sprintf("unable to get attributes of google storage object %v, Error: %v", source.Key, err))
+	return ObjectInfo{
+		Key:          attrs.Name,
+		VersionId:    strconv.FormatInt(attrs.Generation, 10),
+		Size:         attrs.Size,
+		ETag:         attrs.Etag,
+		LastModified: attrs.Updated,
+		Metadata:     attrs.Metadata,
+	}
+}
+
+// isModified checks with a conditional request (generation match) whether source has changed since it had the generation of cached
+func (g GoogleStorage) isModified(source GoStorageObject, cached ObjectInfo) bool {
+	generation, err := strconv.ParseInt(cached.VersionId, 10, 64)
+	if err != nil {
+		return true
+	}
+	_, err = g.objectHandle(source).If(storage.Conditions{GenerationMatch: generation}).Attrs(context.Background())
+	return err != nil
+}
+
+func (g GoogleStorage) setObjectMetadata(target GoStorageObject) {
+	metadata := target.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	_, err := g.objectHandle(target).Update(context.Background(), storage.ObjectAttrsToUpdate{Metadata: metadata})
+	checkErr(err, fmt.Sprintf("unable to set metadata of google storage object %v, Error: %v", target.Key, err))
+}
+
+// canAccess checks whether the credentials of this provider are permitted to read source (object or bucket)
+func (g GoogleStorage) canAccess(source GoStorageObject) bool {
+	if source.Key == "" {
+		_, err := g.getClient().Bucket(source.Bucket).Attrs(context.Background())
+		return err == nil
+	}
+	_, err := g.objectHandle(GoStorageObject{Bucket: source.Bucket, Key: source.Key, VersionId: source.VersionId}).Attrs(context.Background())
+	return err == nil
+}
+
 func (g GoogleStorage) copyFileWithinProvider(source GoStorageObject, target GoStorageObject) {
-	src := g.getClient().Bucket(source.Bucket).Object(source.Key)
-	dst := g.getClient().Bucket(target.Bucket).Object(target.Key)
+	src := g.objectHandle(source)
+	dst := g.objectHandle(GoStorageObject{Bucket: target.Bucket, Key: target.Key, Encryption: target.Encryption})
 
-	_, err := dst.CopierFrom(src).Run(context.Background())
+	copier := dst.CopierFrom(src)
+	if target.ACL != "" {
+		copier.PredefinedACL = target.ACL.googlePredefinedACL()
+	}
+	if target.Metadata != nil {
+		copier.Metadata = target.Metadata
+	}
+	if target.Tags != nil {
+		metadata := target.Metadata
+		if metadata == nil {
+			metadata = g.statObject(source).Metadata
+		}
+		copier.Metadata = withGoogleTags(metadata, target.Tags)
+	}
+	if target.Encryption != nil && target.Encryption.Type == EncryptionKMS {
+		copier.DestinationKMSKeyName = target.Encryption.KMSKeyId
+	}
+	_, err := copier.Run(context.Background())
 	checkErr(err, fmt.Sprintf("unable to copy object from %v to %v, Error: %v", src, dst, err))
 }
 
@@ -129,11 +315,243 @@ func (g GoogleStorage) copyBucketWithinProvider(source GoStorageObject, target G
 	}
 }
 
+func (g GoogleStorage) setBucketVersioning(target GoStorageObject, enabled bool) {
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), storage.BucketAttrsToUpdate{VersioningEnabled: enabled})
+	checkErr(err, fmt.Sprintf("unable to change versioning of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) listObjectVersions(source GoStorageObject) []ObjectVersion {
+	var versions []ObjectVersion
+	objectIterator := g.getClient().Bucket(source.Bucket).Objects(context.Background(), &storage.Query{Prefix: source.Key, Versions: true})
+	for {
+		item, err := objectIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		checkErr(err, fmt.Sprintf("unable to list object versions from bucket %v, Error: %v", source.Bucket, err))
+		versions = append(versions, ObjectVersion{
+			Key:          item.Name,
+			VersionId:    strconv.FormatInt(item.Generation, 10),
+			IsLatest:     item.Deleted.IsZero(),
+			Size:         item.Size,
+			LastModified: item.Updated,
+		})
+	}
+	if source.Key != "" {
+		return filterVersionsByKey(versions, source.Key)
+	}
+	return versions
+}
+
+func (g GoogleStorage) restoreObjectVersion(source GoStorageObject) {
+	target := source
+	target.VersionId = ""
+	g.copyFileWithinProvider(source, target)
+}
+
+func (g GoogleStorage) getLifecycleRules(target GoStorageObject) []LifecycleRule {
+	var rules []LifecycleRule
+	attrs, err := g.getClient().Bucket(target.Bucket).Attrs(context.Background())
+	checkErr(err, fmt.Sprintf("unable to get lifecycle rules of bucket %v, Error: %v", target.Bucket, err))
+
+	for _, r := range attrs.Lifecycle.Rules {
+		rule := LifecycleRule{}
+		switch r.Action.Type {
+		case storage.DeleteAction:
+			if r.Condition.NumNewerVersions != 0 {
+				// NumNewerVersions includes the live version
+				rule.NoncurrentVersionsToKeep = int(r.Condition.NumNewerVersions) - 1
+			} else {
+				rule.ExpirationDays = int(r.Condition.AgeInDays)
+			}
+		case storage.SetStorageClassAction:
+			rule.TransitionDays = int(r.Condition.AgeInDays)
+			rule.TransitionStorageClass = r.Action.StorageClass
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// setLifecycleRules splits each rule into one Google lifecycle rule per action, as Google only supports a single action per rule
+func (g GoogleStorage) setLifecycleRules(target GoStorageObject, rules []LifecycleRule) {
+	lifecycle := &storage.Lifecycle{}
+	for _, rule := range rules {
+		if rule.ExpirationDays != 0 {
+			lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+				Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+				Condition: storage.LifecycleCondition{AgeInDays: int64(rule.ExpirationDays), Liveness: storage.Live},
+			})
+		}
+		if rule.TransitionDays != 0 {
+			lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+				Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: rule.TransitionStorageClass},
+				Condition: storage.LifecycleCondition{AgeInDays: int64(rule.TransitionDays)},
+			})
+		}
+		if rule.NoncurrentVersionsToKeep != 0 {
+			lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+				Action: storage.LifecycleAction{Type: storage.DeleteAction},
+				// NumNewerVersions includes the live version, so one more is needed to keep as many noncurrent versions as on AWS
+				Condition: storage.LifecycleCondition{NumNewerVersions: int64(rule.NoncurrentVersionsToKeep) + 1, Liveness: storage.Archived},
+			})
+		}
+	}
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), storage.BucketAttrsToUpdate{Lifecycle: lifecycle})
+	checkErr(err, fmt.Sprintf("unable to set lifecycle rules of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) deleteLifecycleRules(target GoStorageObject) {
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), storage.BucketAttrsToUpdate{Lifecycle: &storage.Lifecycle{}})
+	checkErr(err, fmt.Sprintf("unable to delete lifecycle rules of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) setObjectACL(target GoStorageObject, acl ACL) {
+	_, err := g.objectHandle(target).Update(context.Background(), storage.ObjectAttrsToUpdate{PredefinedACL: acl.googlePredefinedACL()})
+	checkErr(err, fmt.Sprintf("unable to set ACL of object %v, Error: %v", target.Key, err))
+}
+
+// setPublicAccessBlock enforces public access prevention, unblocking lets the bucket inherit the setting of the organization
+func (g GoogleStorage) setPublicAccessBlock(target GoStorageObject, blocked bool) {
+	prevention := storage.PublicAccessPreventionUnspecified
+	if blocked {
+		prevention = storage.PublicAccessPreventionEnforced
+	}
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), storage.BucketAttrsToUpdate{
+		PublicAccessPrevention: prevention,
+	})
+	checkErr(err, fmt.Sprintf("unable to change public access prevention of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) setUniformBucketLevelAccess(target GoStorageObject, enabled bool) {
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), storage.BucketAttrsToUpdate{
+		UniformBucketLevelAccess: &storage.UniformBucketLevelAccess{Enabled: enabled},
+	})
+	checkErr(err, fmt.Sprintf("unable to change uniform bucket-level access of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) getBucketPolicy(target GoStorageObject) string {
+	policy, err := g.getClient().Bucket(target.Bucket).IAM().Policy(context.Background())
+	checkErr(err, fmt.Sprintf("unable to get policy of bucket %v, Error: %v", target.Bucket, err))
+	policyJson, err := protojson.Marshal(policy.InternalProto)
+	checkErr(err, fmt.Sprintf("unable to serialize policy of bucket %v, Error: %v", target.Bucket, err))
+	return string(policyJson)
+}
+
+func (g GoogleStorage) setBucketPolicy(target GoStorageObject, policy string) {
+	policyProto := &iampb.Policy{}
+	err := protojson.Unmarshal([]byte(policy), policyProto)
+	checkErr(err, fmt.Sprintf("unable to parse policy for bucket %v, Error: %v", target.Bucket, err))
+	err = g.getClient().Bucket(target.Bucket).IAM().SetPolicy(context.Background(), &iam.Policy{InternalProto: policyProto})
+	checkErr(err, fmt.Sprintf("unable to set policy of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) setDefaultBucketEncryption(target GoStorageObject, encryption Encryption) {
+	bucketEncryption := &storage.BucketEncryption{}
+	if encryption.Type == EncryptionKMS {
+		bucketEncryption.DefaultKMSKeyName = encryption.KMSKeyId
+	}
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), storage.BucketAttrsToUpdate{Encryption: bucketEncryption})
+	checkErr(err, fmt.Sprintf("unable to set default encryption of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) getObjectTags(target GoStorageObject) map[string]string {
+	return googleTags(g.statObject(target).Metadata)
+}
+
+// setObjectTags replaces the tags by rewriting the object onto itself, as metadata updates can only add keys. The rewrite
+// keeps the attributes and KMS key of the object and fails if the object has been replaced in the meantime or if
+// target.VersionId is not the live generation.
+func (g GoogleStorage) setObjectTags(target GoStorageObject, tags map[string]string) {
+	src := g.objectHandle(target)
+	attrs, err := src.Attrs(context.Background())
+	checkErr(err, fmt.Sprintf("unable to get attributes of google storage object %v, Error: %v", target.Key, err))
+
+	dst := g.objectHandle(GoStorageObject{Bucket: target.Bucket, Key: target.Key, Encryption: target.Encryption})
+	copier := dst.If(storage.Conditions{GenerationMatch: attrs.Generation}).CopierFrom(src)
+	copier.ContentType = attrs.ContentType
+	copier.ContentEncoding = attrs.ContentEncoding
+	copier.ContentLanguage = attrs.ContentLanguage
+	copier.ContentDisposition = attrs.ContentDisposition
+	copier.CacheControl = attrs.CacheControl
+	copier.StorageClass = attrs.StorageClass
+	copier.ACL = attrs.ACL
+	copier.Metadata = withGoogleTags(attrs.Metadata, tags)
+	if attrs.KMSKeyName != "" {
+		copier.DestinationKMSKeyName = strings.Split(attrs.KMSKeyName, "/cryptoKeyVersions/")[0]
+	}
+	_, err = copier.Run(context.Background())
+	checkErr(err, fmt.Sprintf("unable to set tags of google storage object %v, Error: %v", target.Key, err))
+}
+
+func (g GoogleStorage) deleteObjectTags(target GoStorageObject) {
+	g.setObjectTags(target, nil)
+}
+
+func (g GoogleStorage) getBucketTags(target GoStorageObject) map[string]string {
+	attrs, err := g.getClient().Bucket(target.Bucket).Attrs(context.Background())
+	checkErr(err, fmt.Sprintf("unable to get labels of bucket %v, Error: %v", target.Bucket, err))
+	tags := map[string]string{}
+	for k, v := range attrs.Labels {
+		tags[k] = v
+	}
+	return tags
+}
+
+// setBucketTags replaces the labels of the bucket, labels missing in tags are removed
+func (g GoogleStorage) setBucketTags(target GoStorageObject, tags map[string]string) {
+	attrsToUpdate := storage.BucketAttrsToUpdate{}
+	for k := range g.getBucketTags(target) {
+		if _, ok := tags[k]; !ok {
+			attrsToUpdate.DeleteLabel(k)
+		}
+	}
+	for k, v := range tags {
+		attrsToUpdate.SetLabel(k, v)
+	}
+	_, err := g.getClient().Bucket(target.Bucket).Update(context.Background(), attrsToUpdate)
+	checkErr(err, fmt.Sprintf("unable to set labels of bucket %v, Error: %v", target.Bucket, err))
+}
+
+func (g GoogleStorage) deleteBucketTags(target GoStorageObject) {
+	g.setBucketTags(target, nil)
+}
+
+func (g GoogleStorage) listFilesWithTags(source GoStorageObject, tags map[string]string) []string {
+	var keys []string
+	for _, object := range g.listObjects(source) {
+		if hasTags(googleTags(object.Metadata), tags) {
+			keys = append(keys, object.Key)
+		}
+	}
+	return keys
+}
+
+// objectHandle returns the handle of the object, pinned to a generation if source.VersionId is set and using the customer supplied encryption key if set
+func (g GoogleStorage) objectHandle(source GoStorageObject) *storage.ObjectHandle {
+	objectHandle := g.getClient().Bucket(source.Bucket).Object(source.Key)
+	if source.VersionId != "" {
+		generation, err := strconv.ParseInt(source.VersionId, 10, 64)
+		checkErr(err, fmt.Sprintf("unable to parse generation %v of google storage object %v, Error: %v", source.VersionId, source.Key, err))
+		objectHandle = objectHandle.Generation(generation)
+	}
+	if source.Encryption.usesCustomerKey() {
+		objectHandle = objectHandle.Key(source.Encryption.CustomerKey)
+	}
+	return objectHandle
+}
+
 func (g GoogleStorage) getClient() *storage.Client {
-	if client != nil {
+	return g.clients.getGoogleClient(g.account, func() *storage.Client {
+		if g.CredentialsHolder.GoogleCredentials == nil {
+			fail(nil, "unable to create Google storage client, no Google credentials configured")
+		}
+		clientOptions := []option.ClientOption{option.WithCredentials(g.CredentialsHolder.GoogleCredentials)}
+		if g.config.GoogleEndpoint != "" {
+			clientOptions = append(clientOptions, option.WithEndpoint(g.config.GoogleEndpoint))
+		}
+		client, err := storage.NewClient(context.Background(), clientOptions...)
+		checkErr(err, fmt.Sprintf("unable to create Google storage client, Error: %v", err))
 		return client
-	}
-	client, err := storage.NewClient(context.Background(), option.WithCredentials(g.CredentialsHolder.GoogleCredentials))
-	checkErr(err, fmt.Sprintf("unable to create Google storage client, Error: %v", err))
-	return client
+	})
 }
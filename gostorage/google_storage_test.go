@@ -0,0 +1,131 @@
+package gostorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// newFakeGoogleStorage returns a GoogleStorage whose requests are served by handler
+func newFakeGoogleStorage(t *testing.T, handler http.HandlerFunc) GoogleStorage {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return GoogleStorage{clients: &clientCache{googleClients: map[string]*storage.Client{"": client}}}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestGoogleUploadReportsRejectedUpload(t *testing.T) {
+	var kmsKeyName string
+	g := newFakeGoogleStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		kmsKeyName = r.URL.Query().Get("kmsKeyName")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"invalid KMS key name"}}`))
+	})
+	target := GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderGoogle,
+		Encryption: &Encryption{Type: EncryptionKMS, KMSKeyId: "invalid"}}
+
+	err := catchErrors(func() { g.uploadFile(target, writeTempFile(t, "content")) })
+	if httpStatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("uploadFile returned %v, expected the rejected request", err)
+	}
+	if kmsKeyName != "invalid" {
+		t.Errorf("upload used KMS key %q", kmsKeyName)
+	}
+}
+
+func TestGoogleUpload(t *testing.T) {
+	g := newFakeGoogleStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bucket":"bucket","name":"key"}`))
+	})
+	target := GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderGoogle}
+	if err := catchErrors(func() { g.uploadFile(target, writeTempFile(t, "content")) }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGoogleUploadReportsRejectedACL(t *testing.T) {
+	var predefinedACL string
+	g := newFakeGoogleStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		predefinedACL = r.URL.Query().Get("predefinedAcl")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"cannot use ACL API to set object policy when uniform bucket-level access is enabled"}}`))
+	})
+	target := GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderGoogle, ACL: ACLPublicRead}
+
+	err := catchErrors(func() { g.uploadFile(target, writeTempFile(t, "content")) })
+	if httpStatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("uploadFile returned %v, expected the rejected ACL", err)
+	}
+	if predefinedACL != "publicRead" {
+		t.Errorf("upload used predefined ACL %q", predefinedACL)
+	}
+}
+
+func TestGoogleSetPublicAccessBlock(t *testing.T) {
+	var body map[string]interface{}
+	g := newFakeGoogleStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"name":"bucket"}`))
+	})
+	bucket := GoStorageObject{Bucket: "bucket", ProviderType: ProviderGoogle}
+
+	for blocked, want := range map[bool]string{true: "enforced", false: "unspecified"} {
+		if err := catchErrors(func() { g.setPublicAccessBlock(bucket, blocked) }); err != nil {
+			t.Fatal(err)
+		}
+		iamConfiguration, _ := body["iamConfiguration"].(map[string]interface{})
+		if prevention := iamConfiguration["publicAccessPrevention"]; prevention != want {
+			t.Errorf("setPublicAccessBlock(%v) sent public access prevention %v, expected %v", blocked, prevention, want)
+		}
+	}
+}
+
+func TestGoogleBucketStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   BucketStatus
+	}{
+		{http.StatusOK, BucketAccessible},
+		{http.StatusNotFound, BucketNotFound},
+		{http.StatusForbidden, BucketInaccessible},
+	}
+	for _, test := range tests {
+		g := newFakeGoogleStorage(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statusCode)
+			if test.statusCode == http.StatusOK {
+				w.Write([]byte(`{"name":"bucket"}`))
+			} else {
+				w.Write([]byte(fmt.Sprintf(`{"error":{"code":%v,"message":"error"}}`, test.statusCode)))
+			}
+		})
+		var status BucketStatus
+		if err := catchErrors(func() { status = g.bucketStatus(GoStorageObject{Bucket: "bucket", ProviderType: ProviderGoogle}) }); err != nil {
+			t.Fatalf("%v: %v", test.statusCode, err)
+		}
+		if status != test.expected {
+			t.Errorf("%v: bucket status is %v, expected %v", test.statusCode, status, test.expected)
+		}
+	}
+}
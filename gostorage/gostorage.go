@@ -1,6 +1,7 @@
 package gostorage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -8,14 +9,64 @@ import (
 
 type GoStorage struct {
 	Credentials CredentialsHolder
+	Config      Config
+	// ClientSideEncryption encrypts all objects before they are uploaded, if set
+	ClientSideEncryption *EnvelopeEncryption
+	// Compression compresses all objects before they are uploaded, if set
+	Compression *Compression
+	// CopyOptions are used by Copy and CopyFromString
+	CopyOptions CopyOptions
+	// Logger receives an event per provider operation, nothing is logged if it is not set
+	Logger Logger
+	// Telemetry creates OpenTelemetry spans and metrics, if set
+	Telemetry *Telemetry
+	// Middleware wraps every provider call, the first middleware is the outermost one
+	Middleware []Middleware
+	// ReadCache caches the objects downloaded by DownloadFile and DownloadFileAsReader on local disk, if set
+	ReadCache *ReadCache
+	// Guards are enforced before any provider call (and middleware), if set
+	Guards *Guards
+
+	// clients and knownBuckets are set by New, other instances use the shared caches of their credentials
+	clients      *clientCache
+	knownBuckets *bucketCache
+	ctx          context.Context
+	// confirmedBucket may be deleted although it is not empty, see DeleteBucketWithConfirmation
+	confirmedBucket string
 }
 
-func (s GoStorage) CreateBucket(storageObject GoStorageObject) {
-	storageObject.GetProvider(s.Credentials).createBucket(storageObject.Bucket, storageObject.Region)
+// CreateBucket creates the bucket if it does not exist yet, the result tells whether it was created or already existed
+func (s GoStorage) CreateBucket(storageObject GoStorageObject) CreateBucketResult {
+	s, span := s.startSpan("CreateBucket", storageObject)
+	defer span.End()
+	return s.provider(storageObject).createBucket(storageObject, BucketOptions{})
+}
+
+// BucketExists checks whether the bucket exists, including buckets which can not be accessed with the credentials of
+// storageObject (e.g. owned by another account). Use GetBucketStatus to tell them apart.
+func (s GoStorage) BucketExists(storageObject GoStorageObject) bool {
+	return s.GetBucketStatus(storageObject) != BucketNotFound
+}
+
+// GetBucketStatus checks whether the bucket exists and is accessible with the credentials of storageObject
+func (s GoStorage) GetBucketStatus(storageObject GoStorageObject) BucketStatus {
+	s, span := s.startSpan("GetBucketStatus", storageObject)
+	defer span.End()
+	return s.provider(storageObject).bucketStatus(storageObject)
+}
+
+// ListBuckets lists all buckets of the provider (of the default account, or the Google project configured in Config)
+func (s GoStorage) ListBuckets(providerType ProviderType) []BucketInfo {
+	s, span := s.startSpan("ListBuckets", GoStorageObject{ProviderType: providerType})
+	defer span.End()
+	return s.provider(GoStorageObject{ProviderType: providerType}).listBuckets()
 }
 
 func (s GoStorage) DeleteBucket(storageObject GoStorageObject, deleteIfNotEmpty bool) {
-	storageObject.GetProvider(s.Credentials).deleteBucket(storageObject, deleteIfNotEmpty)
+	s, span := s.startSpan("DeleteBucket", storageObject)
+	defer span.End()
+	s.provider(storageObject).deleteBucket(storageObject, deleteIfNotEmpty)
+	s.bucketCache().remove(storageObject)
 }
 
 func (s GoStorage) CopyFromString(source string, target string) {
@@ -32,69 +83,135 @@ func (s GoStorage) ListFilesInBucketFromString(target string) []string {
 }
 
 func (s GoStorage) Copy(source GoStorageObject, target GoStorageObject) {
+	s, span := s.startSpan("Copy", source)
+	defer span.End()
 	if source.IsLocal && !target.IsLocal { //Upload file
-		target.GetProvider(s.Credentials).createBucket(target.Bucket, target.Region)
-		target.GetProvider(s.Credentials).uploadFile(target, source.LocalFilePath)
+		s.ensureBucket(target)
+		s.provider(target).uploadFile(target, source.LocalFilePath)
 
 	} else if !source.IsLocal && target.IsLocal { //Download file
-		source.GetProvider(s.Credentials).downloadFile(source, target.LocalFilePath)
+		s.provider(source).downloadFile(source, target.LocalFilePath)
 
 	} else if !source.IsLocal && !target.IsLocal { //Copy between (possibly different) providers
-		target.GetProvider(s.Credentials).createBucket(target.Bucket, target.Region)
+		s.ensureBucket(target)
 
-		if source.ProviderType == target.ProviderType {
+		if source.ProviderType == target.ProviderType && s.isServerSideCopyPermitted(source, target) {
 			if source.Key == "" && target.Key == "" {
-				target.GetProvider(s.Credentials).copyBucketWithinProvider(source, target)
+				s.provider(target).copyBucketWithinProvider(source, target)
 			} else if source.Bucket != "" && source.Key != "" {
-				target.GetProvider(s.Credentials).copyFileWithinProvider(source, target)
+				s.provider(target).copyFileWithinProvider(source, target)
 			}
 
-		} else if source.ProviderType != target.ProviderType {
+		} else if source.ProviderType != target.ProviderType || source.Account != target.Account {
 			if source.Key == "" && target.Key == "" {
 				s.copyBucket(source, target)
 			} else if source.Key != "" && target.Key != "" {
 				s.copyFile(source, target)
 			} else {
-				fmt.Fprintln(os.Stderr, "Error:", "Incorrect configuration of source and target key found")
-				os.Exit(1)
+				fail(nil, "Incorrect configuration of source and target key found")
 			}
 
 		} else {
-			fmt.Fprintln(os.Stderr, "Error:", "Incorrect configuration of source and target location found")
-			os.Exit(1)
+			fail(nil, "Incorrect configuration of source and target location found")
 		}
 	}
 }
 
 func (s GoStorage) ListFilesInBucket(target GoStorageObject) []string {
-	return target.GetProvider(s.Credentials).listFilesInBucket(target)
+	s, span := s.startSpan("ListFilesInBucket", target)
+	defer span.End()
+	return s.provider(target).listFilesInBucket(target)
 }
 
 func (s GoStorage) DeleteFile(target GoStorageObject) {
-	target.GetProvider(s.Credentials).deleteFile(target)
+	s, span := s.startSpan("DeleteFile", target)
+	defer span.End()
+	s.provider(target).deleteFile(target)
 }
 
 func (s GoStorage) DeleteFileFromString(url string) {
 	storageObject := parseUrlToGoStorageObject(url)
-	storageObject.GetProvider(s.Credentials).deleteFile(storageObject)
+	s.DeleteFile(storageObject)
 }
 
 func (s GoStorage) UploadFile(source GoStorageObject) {
-	source.GetProvider(s.Credentials).uploadFile(source, source.LocalFilePath)
+	s, span := s.startSpan("UploadFile", source)
+	defer span.End()
+	s.provider(source).uploadFile(source, source.LocalFilePath)
 }
 
 func (s GoStorage) DownloadFileAsReader(source GoStorageObject) io.Reader {
-	return source.GetProvider(s.Credentials).downloadFileAsReader(source)
+	s, span := s.startSpan("DownloadFileAsReader", source)
+	defer span.End()
+	return s.readProvider(source).downloadFileAsReader(source)
 }
 
 func (s GoStorage) DownloadFile(source GoStorageObject, targetFile string) {
-	source.GetProvider(s.Credentials).downloadFile(source, targetFile)
+	s, span := s.startSpan("DownloadFile", source)
+	defer span.End()
+	s.readProvider(source).downloadFile(source, targetFile)
+}
+
+func (s GoStorage) GetObjectInfo(source GoStorageObject) ObjectInfo {
+	s, span := s.startSpan("GetObjectInfo", source)
+	defer span.End()
+	return s.provider(source).statObject(source)
 }
 
 // ---- Helper functions ----
 
+// provider returns the provider of the storage object, wrapped according to the configuration of s
+func (s GoStorage) provider(storageObject GoStorageObject) Provider {
+	provider := s.uncompressedProvider(storageObject)
+	if s.Compression != nil {
+		provider = s.Compression.Wrap(provider)
+	}
+	return provider
+}
+
+// baseProvider returns the provider of the storage object without any wrappers (except for guards, middleware, logging and telemetry)
+func (s GoStorage) baseProvider(storageObject GoStorageObject) Provider {
+	config := s.Config
+	config.Credentials = s.Credentials
+	config.eventLogger = s.Logger
+	provider := storageObject.getProvider(config, s.clients)
+	if s.Logger != nil || s.Telemetry != nil {
+		logger := s.Logger
+		if logger == nil {
+			logger = noopLogger{}
+		}
+		provider = instrumentedProvider{Provider: provider, logger: logger, telemetry: s.Telemetry, ctx: s.context(), providerType: storageObject.ProviderType}
+	}
+	middleware := s.Middleware
+	if s.Guards != nil {
+		middleware = append([]Middleware{s.Guards.guardMiddleware(s.confirmedBucket)}, middleware...)
+	}
+	if len(middleware) > 0 {
+		provider = middlewareProvider{Provider: provider, middleware: middleware, providerType: storageObject.ProviderType}
+	}
+	return provider
+}
+
+// uncompressedProvider returns the provider of the storage object without transparent compression, objects are compressed
+// before they are encrypted
+func (s GoStorage) uncompressedProvider(storageObject GoStorageObject) Provider {
+	provider := s.baseProvider(storageObject)
+	if s.ClientSideEncryption != nil {
+		provider = s.ClientSideEncryption.Wrap(provider)
+	}
+	return provider
+}
+
+// isServerSideCopyPermitted checks whether the account of target is permitted to read source, which is required for server-side copies
+func (s GoStorage) isServerSideCopyPermitted(source GoStorageObject, target GoStorageObject) bool {
+	if source.Account == target.Account {
+		return true
+	}
+	return s.provider(target).canAccess(source)
+}
+
 func (s GoStorage) copyBucket(source GoStorageObject, target GoStorageObject) {
-	filesInBucket := source.GetProvider(s.Credentials).listFilesInBucket(source)
+	filesInBucket := s.provider(source).listFilesInBucket(source)
 	for _, file := range filesInBucket {
 		source.Key = file
 		target.Key = file
@@ -103,13 +220,21 @@ func (s GoStorage) copyBucket(source GoStorageObject, target GoStorageObject) {
 }
 
 func (s GoStorage) copyFile(source GoStorageObject, target GoStorageObject) {
+	s, span := s.startSpan("copyFile", source)
+	defer span.End()
 	tempFile, err := os.CreateTemp(os.TempDir(), source.Key)
 	checkErr(err, fmt.Sprintf("unable to create temporary file, Error: %v", err))
 	tempFilePath := getAbsolutePath(tempFile)
 
-	source.GetProvider(s.Credentials).downloadFile(source, tempFilePath)
+	sourceProvider, targetProvider := s.provider(source), s.provider(target)
+	if s.Compression != nil && s.Compression.KeepCompressedOnCopy {
+		sourceProvider, targetProvider = s.uncompressedProvider(source), s.uncompressedProvider(target)
+		target.Metadata = withCompressionMetadata(sourceProvider.statObject(source), target.Metadata)
+	}
+
+	sourceProvider.downloadFile(source, tempFilePath)
 
-	target.GetProvider(s.Credentials).uploadFile(target, tempFilePath)
+	targetProvider.uploadFile(target, tempFilePath)
 
 	err = os.Remove(tempFilePath)
 	checkErr(err, fmt.Sprintf("unable to delete temp. file %v, Error: %v\n", tempFile.Name(), err))
@@ -0,0 +1,162 @@
+package gostorage
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Guards are enforced before any provider call, violations terminate the process like all other errors. Violations wrap
+// fs.ErrPermission.
+type Guards struct {
+	// ReadOnly rejects all operations modifying buckets or objects
+	ReadOnly bool
+	// Rules allow and deny access to buckets or prefixes per operation type
+	Rules []GuardRule
+	// RequireDeleteConfirmation rejects deleting non-empty buckets unless DeleteBucketWithConfirmation is used
+	RequireDeleteConfirmation bool
+}
+
+// GuardRule restricts Operations (all operations if empty) to the buckets or prefixes of Allow (everything if empty) and
+// rejects the buckets or prefixes of Deny. Patterns are "bucket" or "bucket/prefix". Operations on whole buckets are only
+// allowed by bucket patterns, but they are denied by any pattern of the bucket. Rules are checked against the objects
+// an operation acts on. The source of a copy is checked as read like in copies between providers: as OperationDownload
+// for objects and as OperationListFiles and OperationDownload for buckets. Deleting a bucket with its contents is only
+// checked as OperationDeleteBucket, rules for OperationDeleteFile and OperationDeleteFiles do not apply to it.
+type GuardRule struct {
+	Operations []Operation
+	Allow      []string
+	Deny       []string
+}
+
+// DeleteBucketWithConfirmation deletes the bucket with all its contents, if Guards.RequireDeleteConfirmation is set
+// confirmation must be the name of the bucket
+func (s GoStorage) DeleteBucketWithConfirmation(bucket GoStorageObject, confirmation string) {
+	s, span := s.startSpan("DeleteBucketWithConfirmation", bucket)
+	defer span.End()
+	if confirmation != bucket.Bucket {
+		fail(nil, fmt.Sprintf("confirmation %v does not match bucket %v", confirmation, bucket.Bucket))
+	}
+	s.confirmedBucket = bucket.Bucket
+	s.provider(bucket).deleteBucket(bucket, true)
+	s.bucketCache().remove(bucket)
+}
+
+// checkGuards fails like the provider call of request would if the guards reject it, without calling the provider
+func (s GoStorage) checkGuards(request Request) {
+	if s.Guards != nil {
+		s.Guards.guardMiddleware(s.confirmedBucket)(request, func(Request) Response { return Response{} })
+	}
+}
+
+// guardMiddleware returns the middleware enforcing the guards, confirmedBucket may be deleted even if it is not empty
+func (g Guards) guardMiddleware(confirmedBucket string) Middleware {
+	return func(request Request, next Handler) Response {
+		if g.ReadOnly && request.Operation.modifiesStorage() {
+			fail(fs.ErrPermission, fmt.Sprintf("operation %v on %v is not permitted in read-only mode", request.Operation, request.Object.Bucket))
+		}
+		for _, access := range request.guardedAccesses() {
+			if access.object.Bucket == "" {
+				continue
+			}
+			for _, rule := range g.Rules {
+				if err := rule.check(access.operation, access.object); err != nil {
+					fail(fs.ErrPermission, err)
+				}
+			}
+		}
+		if g.RequireDeleteConfirmation && request.Operation == OperationDeleteBucket && request.DeleteIfNotEmpty &&
+			request.Object.Bucket != confirmedBucket {
+			versions := next(Request{Operation: OperationListObjectVersions, Object: GoStorageObject{Bucket: request.Object.Bucket,
+				Region: request.Object.Region, ProviderType: request.Object.ProviderType, Account: request.Object.Account}}).Versions
+			if len(versions) > 0 {
+				fail(fs.ErrPermission, fmt.Sprintf("bucket %v is not empty, use DeleteBucketWithConfirmation to delete it", request.Object.Bucket))
+			}
+		}
+		return next(request)
+	}
+}
+
+// guardedAccess is an operation on an object which the guard rules are checked against
+type guardedAccess struct {
+	operation Operation
+	object    GoStorageObject
+}
+
+// guardedAccesses returns the accesses of the request: the target of copies and the reads of their source, the objects
+// of batch deletes and the object of all other operations
+func (r Request) guardedAccesses() []guardedAccess {
+	switch r.Operation {
+	case OperationCopyFile:
+		return []guardedAccess{{OperationDownload, r.Object}, {r.Operation, r.Target}}
+	case OperationCopyBucket:
+		return []guardedAccess{{OperationListFiles, r.Object}, {OperationDownload, r.Object}, {r.Operation, r.Target}}
+	case OperationDeleteFiles:
+		accesses := make([]guardedAccess, len(r.Objects))
+		for i, object := range r.Objects {
+			accesses[i] = guardedAccess{r.Operation, object}
+		}
+		return accesses
+	default:
+		return []guardedAccess{{r.Operation, r.Object}}
+	}
+}
+
+// check returns an error if the rule does not permit the operation on object
+func (r GuardRule) check(operation Operation, object GoStorageObject) error {
+	if len(r.Operations) > 0 && !containsOperation(r.Operations, operation) {
+		return nil
+	}
+	for _, pattern := range r.Deny {
+		if matchesGuardPattern(pattern, object, true) {
+			return fmt.Errorf("operation %v on %v/%v is denied by %v", operation, object.Bucket, object.Key, pattern)
+		}
+	}
+	if len(r.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range r.Allow {
+		if matchesGuardPattern(pattern, object, false) {
+			return nil
+		}
+	}
+	return fmt.Errorf("operation %v on %v/%v is not allowed", operation, object.Bucket, object.Key)
+}
+
+// matchesGuardPattern checks whether object is covered by "bucket" or "bucket/prefix". Whole buckets (empty key) match
+// prefix patterns only if matchBucketByPrefix is set.
+func matchesGuardPattern(pattern string, object GoStorageObject, matchBucketByPrefix bool) bool {
+	bucket, prefix := pattern, ""
+	if i := strings.Index(pattern, "/"); i != -1 {
+		bucket, prefix = pattern[:i], pattern[i+1:]
+	}
+	if bucket != object.Bucket {
+		return false
+	}
+	if object.Key == "" {
+		return prefix == "" || matchBucketByPrefix
+	}
+	return strings.HasPrefix(object.Key, prefix)
+}
+
+func containsOperation(operations []Operation, operation Operation) bool {
+	for _, o := range operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiesStorage checks whether the operation creates, changes or deletes buckets or objects
+func (o Operation) modifiesStorage() bool {
+	switch o {
+	case OperationBucketExists, OperationListBuckets, OperationCanAccess, OperationDownload, OperationDownloadAsReader,
+		OperationListFiles, OperationListDirectory, OperationListObjects, OperationStatObject, OperationIsModified,
+		OperationListObjectVersions, OperationGetLifecycleRules, OperationGetBucketPolicy, OperationGetObjectTags,
+		OperationGetBucketTags, OperationListFilesWithTags:
+		return false
+	default:
+		return true
+	}
+}
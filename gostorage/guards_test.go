@@ -0,0 +1,93 @@
+package gostorage
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestGuards(t *testing.T) {
+	object := func(bucket, key string) GoStorageObject {
+		return GoStorageObject{Bucket: bucket, Key: key, ProviderType: ProviderAWS}
+	}
+	prefixRules := Guards{Rules: []GuardRule{{
+		Operations: []Operation{OperationUpload, OperationDownload},
+		Allow:      []string{"data/public/", "logs"},
+		Deny:       []string{"data/public/secret"},
+	}}}
+	operationRules := Guards{Rules: []GuardRule{{Operations: []Operation{OperationDeleteFile, OperationDeleteFiles}, Deny: []string{"data"}}}}
+	denyAll := Guards{Rules: []GuardRule{{Deny: []string{"locked"}}}}
+	denyReads := Guards{Rules: []GuardRule{{Operations: []Operation{OperationDownload}, Deny: []string{"data/private/"}}}}
+	confirmation := Guards{RequireDeleteConfirmation: true}
+
+	tests := []struct {
+		name      string
+		guards    Guards
+		confirmed string
+		request   Request
+		// versions returned for the emptiness check of bucket deletions
+		versions []ObjectVersion
+		denied   bool
+	}{
+		{"allowed prefix", prefixRules, "", Request{Operation: OperationUpload, Object: object("data", "public/a")}, nil, false},
+		{"other prefix", prefixRules, "", Request{Operation: OperationUpload, Object: object("data", "private/a")}, nil, true},
+		{"denied prefix", prefixRules, "", Request{Operation: OperationDownload, Object: object("data", "public/secret/a")}, nil, true},
+		{"allowed bucket", prefixRules, "", Request{Operation: OperationDownload, Object: object("logs", "a")}, nil, false},
+		{"operation without rule", prefixRules, "", Request{Operation: OperationDeleteFile, Object: object("data", "private/a")}, nil, false},
+		{"whole bucket by prefix pattern", prefixRules, "", Request{Operation: OperationUpload, Object: object("data", "")}, nil, true},
+
+		{"operation rule", operationRules, "", Request{Operation: OperationDeleteFile, Object: object("data", "a")}, nil, true},
+		{"other operation", operationRules, "", Request{Operation: OperationDownload, Object: object("data", "a")}, nil, false},
+		{"batch delete", operationRules, "", Request{Operation: OperationDeleteFiles,
+			Objects: []GoStorageObject{object("other", "a"), object("data", "b")}}, nil, true},
+
+		{"copy out of denied bucket", denyAll, "", Request{Operation: OperationCopyFile, Object: object("locked", "a"),
+			Target: object("open", "a")}, nil, true},
+		{"copy into denied bucket", denyAll, "", Request{Operation: OperationCopyFile, Object: object("open", "a"),
+			Target: object("locked", "a")}, nil, true},
+		{"bucket copy out of denied bucket", denyAll, "", Request{Operation: OperationCopyBucket, Object: object("locked", ""),
+			Target: object("open", "")}, nil, true},
+		{"copy between other buckets", denyAll, "", Request{Operation: OperationCopyFile, Object: object("open", "a"),
+			Target: object("other", "a")}, nil, false},
+		{"copy of unreadable object", denyReads, "", Request{Operation: OperationCopyFile, Object: object("data", "private/a"),
+			Target: object("open", "a")}, nil, true},
+		{"copy of readable object", denyReads, "", Request{Operation: OperationCopyFile, Object: object("data", "public/a"),
+			Target: object("open", "a")}, nil, false},
+		{"bucket copy with unreadable prefix", denyReads, "", Request{Operation: OperationCopyBucket, Object: object("data", ""),
+			Target: object("open", "")}, nil, true},
+
+		{"read-only upload", Guards{ReadOnly: true}, "", Request{Operation: OperationUpload, Object: object("data", "a")}, nil, true},
+		{"read-only download", Guards{ReadOnly: true}, "", Request{Operation: OperationDownload, Object: object("data", "a")}, nil, false},
+
+		{"delete non-empty bucket", confirmation, "", Request{Operation: OperationDeleteBucket, Object: object("data", ""),
+			DeleteIfNotEmpty: true}, []ObjectVersion{{Key: "a"}}, true},
+		{"delete confirmed bucket", confirmation, "data", Request{Operation: OperationDeleteBucket, Object: object("data", ""),
+			DeleteIfNotEmpty: true}, []ObjectVersion{{Key: "a"}}, false},
+		{"delete other than confirmed bucket", confirmation, "other", Request{Operation: OperationDeleteBucket,
+			Object: object("data", ""), DeleteIfNotEmpty: true}, []ObjectVersion{{Key: "a"}}, true},
+		{"delete empty bucket", confirmation, "", Request{Operation: OperationDeleteBucket, Object: object("data", ""),
+			DeleteIfNotEmpty: true}, nil, false},
+		{"bucket deletion by delete file rule", operationRules, "", Request{Operation: OperationDeleteBucket,
+			Object: object("data", ""), DeleteIfNotEmpty: true}, nil, false},
+	}
+	for _, test := range tests {
+		called := false
+		next := func(request Request) Response {
+			if request.Operation == OperationListObjectVersions {
+				return Response{Versions: test.versions}
+			}
+			called = true
+			return Response{}
+		}
+		err := catchErrors(func() { test.guards.guardMiddleware(test.confirmed)(test.request, next) })
+		if test.denied {
+			if err == nil || called {
+				t.Errorf("%v: request was permitted", test.name)
+			} else if !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("%v: error %v does not wrap fs.ErrPermission", test.name, err)
+			}
+		} else if err != nil || !called {
+			t.Errorf("%v: request was denied: %v", test.name, err)
+		}
+	}
+}
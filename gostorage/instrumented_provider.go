@@ -0,0 +1,327 @@
+package gostorage
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedProvider logs and traces every provider operation and records its metrics. A debug event is emitted when
+// an operation starts and an event with provider, bucket, key, bytes, duration and error when it is finished.
+// Failing operations are recorded before the failure is passed on.
+type instrumentedProvider struct {
+	Provider
+	logger       Logger
+	telemetry    *Telemetry
+	ctx          context.Context
+	providerType ProviderType
+}
+
+type providerOperation struct {
+	provider instrumentedProvider
+	name     string
+	object   GoStorageObject
+	started  time.Time
+	ctx      context.Context
+	span     trace.Span
+}
+
+func (p instrumentedProvider) start(name string, object GoStorageObject) providerOperation {
+	p.logger.Debug("gostorage operation started", "operation", name, "provider", p.providerType, "bucket", object.Bucket, "key", object.Key)
+	operation := providerOperation{provider: p, name: name, object: object, ctx: p.ctx}
+	if p.telemetry != nil {
+		operation.ctx, operation.span = p.telemetry.startSpan(p.ctx, "Provider."+name, object)
+	}
+	operation.started = time.Now()
+	return operation
+}
+
+// call runs f as operation name on object, a failure of f is recorded and then passed on
+func (p instrumentedProvider) call(name string, object GoStorageObject, f func()) {
+	op := p.start(name, object)
+	op.finish(0, catchErrors(f))
+}
+
+// finish ends the operation and passes a failure of the operation on
+func (o providerOperation) finish(bytes int64, err error) {
+	o.end(bytes, err)
+	if err != nil {
+		failWith(err)
+	}
+}
+
+func (o providerOperation) end(bytes int64, err error) {
+	duration := o.record(bytes, err)
+	o.provider.finished(o.name, o.object, bytes, duration, err)
+}
+
+// record ends the span and records the metrics of the operation, it returns the duration of the operation
+func (o providerOperation) record(bytes int64, err error) time.Duration {
+	duration := time.Since(o.started)
+	if o.provider.telemetry != nil {
+		o.provider.telemetry.record(o.ctx, o.provider.providerType, o.name, bytes, duration.Seconds(), err)
+		setSpanError(o.span, err)
+		o.span.End()
+	}
+	return duration
+}
+
+// finished emits the event of a finished operation
+func (p instrumentedProvider) finished(name string, object GoStorageObject, bytes int64, duration time.Duration, err error) {
+	args := []interface{}{"operation", name, "provider", p.providerType, "bucket", object.Bucket, "key", object.Key,
+		"bytes", bytes, "duration", duration}
+	if err != nil {
+		p.logger.Error("gostorage operation failed", append(args, "error", err)...)
+		return
+	}
+	p.logger.Info("gostorage operation finished", args...)
+}
+
+func (p instrumentedProvider) createBucket(target GoStorageObject, options BucketOptions) (result CreateBucketResult) {
+	p.call("createBucket", target, func() { result = p.Provider.createBucket(target, options) })
+	return result
+}
+
+func (p instrumentedProvider) bucketStatus(target GoStorageObject) (status BucketStatus) {
+	p.call("bucketStatus", target, func() { status = p.Provider.bucketStatus(target) })
+	return status
+}
+
+func (p instrumentedProvider) listBuckets() (buckets []BucketInfo) {
+	p.call("listBuckets", GoStorageObject{ProviderType: p.providerType}, func() { buckets = p.Provider.listBuckets() })
+	return buckets
+}
+
+func (p instrumentedProvider) deleteBucket(target GoStorageObject, deleteIfNotEmpty bool) {
+	p.call("deleteBucket", target, func() { p.Provider.deleteBucket(target, deleteIfNotEmpty) })
+}
+
+func (p instrumentedProvider) copyFileWithinProvider(source GoStorageObject, target GoStorageObject) {
+	p.call("copyFileWithinProvider", target, func() { p.Provider.copyFileWithinProvider(source, target) })
+}
+
+func (p instrumentedProvider) copyBucketWithinProvider(source GoStorageObject, target GoStorageObject) {
+	op := p.start("copyBucketWithinProvider", target)
+	if p.telemetry == nil {
+		op.finish(0, catchErrors(func() { p.Provider.copyBucketWithinProvider(source, target) }))
+		return
+	}
+	// objects are copied one by one, so every object gets its own child span, they are not logged to keep one event per call
+	objectProvider := p
+	objectProvider.ctx = op.ctx
+	objectProvider.logger = noopLogger{}
+	op.finish(0, catchErrors(func() {
+		for _, key := range objectProvider.listFilesInBucket(source) {
+			source.Key = key
+			target.Key = key
+			objectProvider.copyFileWithinProvider(source, target)
+		}
+	}))
+}
+
+func (p instrumentedProvider) canAccess(source GoStorageObject) (permitted bool) {
+	p.call("canAccess", source, func() { permitted = p.Provider.canAccess(source) })
+	return permitted
+}
+
+func (p instrumentedProvider) uploadFile(target GoStorageObject, sourceFile string) {
+	op := p.start("uploadFile", target)
+	err := catchErrors(func() { p.Provider.uploadFile(target, sourceFile) })
+	op.finish(fileSize(sourceFile), err)
+}
+
+func (p instrumentedProvider) downloadFile(source GoStorageObject, targetFile string) {
+	op := p.start("downloadFile", source)
+	err := catchErrors(func() { p.Provider.downloadFile(source, targetFile) })
+	op.finish(fileSize(targetFile), err)
+}
+
+// downloadFileAsReader emits the finished event when the reader is exhausted or closed
+func (p instrumentedProvider) downloadFileAsReader(source GoStorageObject) io.Reader {
+	op := p.start("downloadFileAsReader", source)
+	var reader io.Reader
+	if err := catchErrors(func() { reader = p.Provider.downloadFileAsReader(source) }); err != nil {
+		op.finish(0, err)
+	}
+	return &countingReader{Reader: reader, done: func(bytes int64, err error) {
+		op.end(bytes, err)
+	}}
+}
+
+func (p instrumentedProvider) listFilesInBucket(source GoStorageObject) (keys []string) {
+	p.call("listFilesInBucket", source, func() { keys = p.Provider.listFilesInBucket(source) })
+	return keys
+}
+
+func (p instrumentedProvider) listDirectory(source GoStorageObject) (keys []string, prefixes []string) {
+	p.call("listDirectory", source, func() { keys, prefixes = p.Provider.listDirectory(source) })
+	return keys, prefixes
+}
+
+func (p instrumentedProvider) listObjects(source GoStorageObject) (objects []ObjectInfo) {
+	p.call("listObjects", source, func() { objects = p.Provider.listObjects(source) })
+	return objects
+}
+
+func (p instrumentedProvider) deleteFile(target GoStorageObject) {
+	p.call("deleteFile", target, func() { p.Provider.deleteFile(target) })
+}
+
+// deleteFiles emits one finished deleteFile event per object, the batch is traced and measured as a whole
+func (p instrumentedProvider) deleteFiles(targets []GoStorageObject) []DeleteResult {
+	if len(targets) == 0 {
+		return p.Provider.deleteFiles(targets)
+	}
+	op := p.start("deleteFiles", targets[0])
+	var results []DeleteResult
+	if err := catchErrors(func() { results = p.Provider.deleteFiles(targets) }); err != nil {
+		op.finish(0, err)
+	}
+	duration := op.record(0, firstDeleteError(results))
+	for i, result := range results {
+		p.finished("deleteFile", targets[i], 0, duration, result.Err)
+	}
+	return results
+}
+
+func (p instrumentedProvider) statObject(source GoStorageObject) (info ObjectInfo) {
+	p.call("statObject", source, func() { info = p.Provider.statObject(source) })
+	return info
+}
+
+func (p instrumentedProvider) isModified(source GoStorageObject, cached ObjectInfo) (modified bool) {
+	p.call("isModified", source, func() { modified = p.Provider.isModified(source, cached) })
+	return modified
+}
+
+func (p instrumentedProvider) setObjectMetadata(target GoStorageObject) {
+	p.call("setObjectMetadata", target, func() { p.Provider.setObjectMetadata(target) })
+}
+
+func (p instrumentedProvider) setBucketVersioning(target GoStorageObject, enabled bool) {
+	p.call("setBucketVersioning", target, func() { p.Provider.setBucketVersioning(target, enabled) })
+}
+
+func (p instrumentedProvider) listObjectVersions(source GoStorageObject) (versions []ObjectVersion) {
+	p.call("listObjectVersions", source, func() { versions = p.Provider.listObjectVersions(source) })
+	return versions
+}
+
+func (p instrumentedProvider) restoreObjectVersion(source GoStorageObject) {
+	p.call("restoreObjectVersion", source, func() { p.Provider.restoreObjectVersion(source) })
+}
+
+func (p instrumentedProvider) getLifecycleRules(target GoStorageObject) (rules []LifecycleRule) {
+	p.call("getLifecycleRules", target, func() { rules = p.Provider.getLifecycleRules(target) })
+	return rules
+}
+
+func (p instrumentedProvider) setLifecycleRules(target GoStorageObject, rules []LifecycleRule) {
+	p.call("setLifecycleRules", target, func() { p.Provider.setLifecycleRules(target, rules) })
+}
+
+func (p instrumentedProvider) deleteLifecycleRules(target GoStorageObject) {
+	p.call("deleteLifecycleRules", target, func() { p.Provider.deleteLifecycleRules(target) })
+}
+
+func (p instrumentedProvider) setObjectACL(target GoStorageObject, acl ACL) {
+	p.call("setObjectACL", target, func() { p.Provider.setObjectACL(target, acl) })
+}
+
+func (p instrumentedProvider) setPublicAccessBlock(target GoStorageObject, blocked bool) {
+	p.call("setPublicAccessBlock", target, func() { p.Provider.setPublicAccessBlock(target, blocked) })
+}
+
+func (p instrumentedProvider) setUniformBucketLevelAccess(target GoStorageObject, enabled bool) {
+	p.call("setUniformBucketLevelAccess", target, func() { p.Provider.setUniformBucketLevelAccess(target, enabled) })
+}
+
+func (p instrumentedProvider) getBucketPolicy(target GoStorageObject) (policy string) {
+	p.call("getBucketPolicy", target, func() { policy = p.Provider.getBucketPolicy(target) })
+	return policy
+}
+
+func (p instrumentedProvider) setBucketPolicy(target GoStorageObject, policy string) {
+	p.call("setBucketPolicy", target, func() { p.Provider.setBucketPolicy(target, policy) })
+}
+
+func (p instrumentedProvider) setDefaultBucketEncryption(target GoStorageObject, encryption Encryption) {
+	p.call("setDefaultBucketEncryption", target, func() { p.Provider.setDefaultBucketEncryption(target, encryption) })
+}
+
+func (p instrumentedProvider) getObjectTags(target GoStorageObject) (tags map[string]string) {
+	p.call("getObjectTags", target, func() { tags = p.Provider.getObjectTags(target) })
+	return tags
+}
+
+func (p instrumentedProvider) setObjectTags(target GoStorageObject, tags map[string]string) {
+	p.call("setObjectTags", target, func() { p.Provider.setObjectTags(target, tags) })
+}
+
+func (p instrumentedProvider) deleteObjectTags(target GoStorageObject) {
+	p.call("deleteObjectTags", target, func() { p.Provider.deleteObjectTags(target) })
+}
+
+func (p instrumentedProvider) getBucketTags(target GoStorageObject) (tags map[string]string) {
+	p.call("getBucketTags", target, func() { tags = p.Provider.getBucketTags(target) })
+	return tags
+}
+
+func (p instrumentedProvider) setBucketTags(target GoStorageObject, tags map[string]string) {
+	p.call("setBucketTags", target, func() { p.Provider.setBucketTags(target, tags) })
+}
+
+func (p instrumentedProvider) deleteBucketTags(target GoStorageObject) {
+	p.call("deleteBucketTags", target, func() { p.Provider.deleteBucketTags(target) })
+}
+
+func (p instrumentedProvider) listFilesWithTags(source GoStorageObject, tags map[string]string) (keys []string) {
+	p.call("listFilesWithTags", source, func() { keys = p.Provider.listFilesWithTags(source, tags) })
+	return keys
+}
+
+// countingReader counts the bytes read and calls done once when the reader is exhausted, fails or is closed
+type countingReader struct {
+	io.Reader
+	bytes    int64
+	done     func(bytes int64, err error)
+	finished bool
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.bytes += int64(n)
+	if err == io.EOF {
+		r.finish(nil)
+	} else if err != nil {
+		r.finish(err)
+	}
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	r.finish(nil)
+	if closer, ok := r.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (r *countingReader) finish(err error) {
+	if !r.finished {
+		r.finished = true
+		r.done(r.bytes, err)
+	}
+}
+
+// fileSize returns the size of the local file or 0 if it can not be determined
+func fileSize(filePath string) int64 {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return fileInfo.Size()
+}
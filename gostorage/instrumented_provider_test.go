@@ -0,0 +1,146 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// statProvider only implements statObject, which fails with err if it is set
+type statProvider struct {
+	Provider
+	err error
+}
+
+func (p statProvider) statObject(source GoStorageObject) ObjectInfo {
+	checkErr(p.err, fmt.Sprintf("unable to get attributes of object %v, Error: %v", source.Key, p.err))
+	return ObjectInfo{Key: source.Key}
+}
+
+func newTestTelemetry() (*Telemetry, *tracetest.SpanRecorder, sdkmetric.Reader) {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	return &Telemetry{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	}, spans, reader
+}
+
+// counterValue returns the sum of the counter with the given name
+func counterValue(t *testing.T, reader sdkmetric.Reader, name string) int64 {
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	var value int64
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == name {
+				for _, point := range sum.DataPoints {
+					value += point.Value
+				}
+			}
+		}
+	}
+	return value
+}
+
+func TestInstrumentedProviderRecordsFailures(t *testing.T) {
+	telemetry, spans, reader := newTestTelemetry()
+	provider := instrumentedProvider{Provider: statProvider{err: errors.New("access denied")}, logger: noopLogger{}, telemetry: telemetry,
+		ctx: context.Background(), providerType: ProviderAWS}
+	object := GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderAWS}
+
+	err := catchErrors(func() { provider.statObject(object) })
+	if err == nil {
+		t.Fatal("the failure of the provider was not passed on")
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 || ended[0].Name() != "Provider.statObject" {
+		t.Fatalf("expected one Provider.statObject span, got %v", len(ended))
+	}
+	if status := ended[0].Status(); status.Code != codes.Error || status.Description != err.Error() {
+		t.Errorf("span status is %v %q, expected the error", status.Code, status.Description)
+	}
+	if requests := counterValue(t, reader, "gostorage.requests"); requests != 1 {
+		t.Errorf("gostorage.requests is %v, expected 1", requests)
+	}
+	if errorCount := counterValue(t, reader, "gostorage.errors"); errorCount != 1 {
+		t.Errorf("gostorage.errors is %v, expected 1", errorCount)
+	}
+}
+
+func TestInstrumentedProviderRecordsSuccess(t *testing.T) {
+	telemetry, spans, reader := newTestTelemetry()
+	provider := instrumentedProvider{Provider: statProvider{}, logger: noopLogger{}, telemetry: telemetry,
+		ctx: context.Background(), providerType: ProviderGoogle}
+
+	if err := catchErrors(func() {
+		provider.statObject(GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderGoogle})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ended := spans.Ended(); len(ended) != 1 || ended[0].Status().Code == codes.Error {
+		t.Errorf("expected one successful span, got %v", ended)
+	}
+	if errorCount := counterValue(t, reader, "gostorage.errors"); errorCount != 0 {
+		t.Errorf("gostorage.errors is %v, expected 0", errorCount)
+	}
+}
+
+type logEvent struct {
+	level string
+	msg   string
+	args  map[interface{}]interface{}
+}
+
+// recordingLogger records all events
+type recordingLogger struct {
+	events *[]logEvent
+}
+
+func (l recordingLogger) record(level string, msg string, args []interface{}) {
+	event := logEvent{level: level, msg: msg, args: map[interface{}]interface{}{}}
+	for i := 0; i+1 < len(args); i += 2 {
+		event.args[args[i]] = args[i+1]
+	}
+	*l.events = append(*l.events, event)
+}
+
+func (l recordingLogger) Debug(msg string, args ...interface{}) { l.record("debug", msg, args) }
+func (l recordingLogger) Info(msg string, args ...interface{})  { l.record("info", msg, args) }
+func (l recordingLogger) Warn(msg string, args ...interface{})  { l.record("warn", msg, args) }
+func (l recordingLogger) Error(msg string, args ...interface{}) { l.record("error", msg, args) }
+
+func TestInstrumentedProviderLogsFailures(t *testing.T) {
+	var events []logEvent
+	provider := instrumentedProvider{Provider: statProvider{err: errors.New("access denied")}, logger: recordingLogger{&events},
+		ctx: context.Background(), providerType: ProviderAWS}
+
+	err := catchErrors(func() { provider.statObject(GoStorageObject{Bucket: "bucket", Key: "key", ProviderType: ProviderAWS}) })
+	if err == nil {
+		t.Fatal("the failure of the provider was not passed on")
+	}
+	if len(events) != 2 || events[0].level != "debug" {
+		t.Fatalf("expected a start and a finished event, got %v", events)
+	}
+	failed := events[1]
+	if failed.level != "error" || failed.args["operation"] != "statObject" || failed.args["key"] != "key" {
+		t.Errorf("unexpected event %v", failed)
+	}
+	if loggedErr, ok := failed.args["error"].(error); !ok || loggedErr.Error() != err.Error() {
+		t.Errorf("event error is %v, expected %v", failed.args["error"], err)
+	}
+	if _, ok := failed.args["duration"].(time.Duration); !ok {
+		t.Errorf("event has no duration: %v", failed.args)
+	}
+}
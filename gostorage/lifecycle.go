@@ -0,0 +1,76 @@
+package gostorage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LifecycleRule provider-neutral description of a bucket lifecycle rule. Only the non-zero actions of a rule are applied.
+// StorageClass values are passed to the provider as they are (e.g. GLACIER for AWS, COLDLINE for Google).
+// AWS rules with several transitions are returned as one LifecycleRule per transition with the same ID, rules with the
+// same ID are combined into one AWS rule again when they are set.
+type LifecycleRule struct {
+	ID                                 string
+	Prefix                             string
+	ExpirationDays                     int
+	TransitionDays                     int
+	TransitionStorageClass             string
+	AbortIncompleteMultipartUploadDays int
+	NoncurrentVersionExpirationDays    int
+	NoncurrentVersionsToKeep           int
+}
+
+func (s GoStorage) GetLifecycleRules(bucket GoStorageObject) []LifecycleRule {
+	s, span := s.startSpan("GetLifecycleRules", bucket)
+	defer span.End()
+	return s.provider(bucket).getLifecycleRules(bucket)
+}
+
+// SetLifecycleRules replaces all lifecycle rules of the bucket, rules which can not be expressed by the provider are rejected.
+// Setting no rules deletes all lifecycle rules.
+func (s GoStorage) SetLifecycleRules(bucket GoStorageObject, rules []LifecycleRule) {
+	s, span := s.startSpan("SetLifecycleRules", bucket)
+	defer span.End()
+	for _, rule := range rules {
+		err := rule.Validate(bucket.ProviderType)
+		checkErr(err, fmt.Sprintf("unable to set lifecycle rules of bucket %v, Error: %v", bucket.Bucket, err))
+	}
+	s.provider(bucket).setLifecycleRules(bucket, rules)
+}
+
+func (s GoStorage) DeleteLifecycleRules(bucket GoStorageObject) {
+	s, span := s.startSpan("DeleteLifecycleRules", bucket)
+	defer span.End()
+	s.provider(bucket).deleteLifecycleRules(bucket)
+}
+
+// Validate checks whether the rule can be expressed by the given provider
+func (r LifecycleRule) Validate(providerType ProviderType) error {
+	if r.ExpirationDays == 0 && r.TransitionDays == 0 && r.AbortIncompleteMultipartUploadDays == 0 &&
+		r.NoncurrentVersionExpirationDays == 0 && r.NoncurrentVersionsToKeep == 0 {
+		return fmt.Errorf("lifecycle rule %v does not contain any action", r.ID)
+	}
+	if (r.TransitionDays != 0) != (r.TransitionStorageClass != "") {
+		return fmt.Errorf("lifecycle rule %v needs both transition days and storage class", r.ID)
+	}
+
+	switch providerType {
+	case ProviderAWS:
+		if r.NoncurrentVersionsToKeep != 0 && r.NoncurrentVersionExpirationDays == 0 {
+			return errors.New("AWS requires noncurrent version expiration days when keeping a number of noncurrent versions")
+		}
+	case ProviderGoogle:
+		if r.Prefix != "" {
+			return errors.New("Google lifecycle rules do not support prefix filters")
+		}
+		if r.AbortIncompleteMultipartUploadDays != 0 {
+			return errors.New("Google lifecycle rules do not support aborting incomplete multipart uploads")
+		}
+		if r.NoncurrentVersionExpirationDays != 0 {
+			return errors.New("Google lifecycle rules do not support expiring noncurrent versions after a number of days, use NoncurrentVersionsToKeep instead")
+		}
+	default:
+		return fmt.Errorf("unknown provider type %v", providerType)
+	}
+	return nil
+}
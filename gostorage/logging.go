@@ -0,0 +1,25 @@
+package gostorage
+
+// Logger receives structured log events as message and key-value pairs, it is satisfied by *slog.Logger
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// noopLogger discards all events, it is used if no Logger is configured
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...interface{}) {}
+func (noopLogger) Info(string, ...interface{})  {}
+func (noopLogger) Warn(string, ...interface{})  {}
+func (noopLogger) Error(string, ...interface{}) {}
+
+// logger returns the configured logger or a logger discarding all events
+func (c Config) logger() Logger {
+	if c.eventLogger != nil {
+		return c.eventLogger
+	}
+	return noopLogger{}
+}
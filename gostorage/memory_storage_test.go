@@ -0,0 +1,136 @@
+package gostorage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+)
+
+// memoryStorage serves the object operations of all providers from memory. Like S3 and Google Storage, copies replace
+// the metadata if the target has metadata and keep the metadata of the source otherwise.
+type memoryStorage struct {
+	mutex   sync.Mutex
+	objects map[string]*memoryObject
+	version int
+	// calls counts the requests by operation
+	calls map[Operation]int
+}
+
+type memoryObject struct {
+	content  []byte
+	metadata map[string]string
+	version  int
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{objects: map[string]*memoryObject{}, calls: map[Operation]int{}}
+}
+
+func memoryKey(object GoStorageObject) string {
+	return string(object.ProviderType) + "/" + object.Bucket + "/" + object.Key
+}
+
+func (m *memoryStorage) put(object GoStorageObject, content []byte, metadata map[string]string) {
+	m.version++
+	copied := map[string]string{}
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	m.objects[memoryKey(object)] = &memoryObject{content: content, metadata: copied, version: m.version}
+}
+
+// get returns the object or fails with a not found error
+func (m *memoryStorage) get(object GoStorageObject) *memoryObject {
+	stored, ok := m.objects[memoryKey(object)]
+	if !ok || (object.VersionId != "" && object.VersionId != strconv.Itoa(stored.version)) {
+		err := &googleapi.Error{Code: http.StatusNotFound}
+		checkErr(err, "object "+object.Key+" does not exist")
+	}
+	return stored
+}
+
+// keys returns the sorted keys of the objects in the bucket of object which start with object.Key
+func (m *memoryStorage) keys(object GoStorageObject) []string {
+	prefix := memoryKey(object)
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, strings.TrimPrefix(key, memoryKey(GoStorageObject{ProviderType: object.ProviderType, Bucket: object.Bucket})))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *memoryStorage) info(key string, stored *memoryObject) ObjectInfo {
+	return ObjectInfo{Key: key, VersionId: strconv.Itoa(stored.version), ETag: strconv.Itoa(stored.version),
+		Size: int64(len(stored.content)), Metadata: stored.metadata}
+}
+
+func (m *memoryStorage) copy(source GoStorageObject, target GoStorageObject) {
+	stored := m.get(source)
+	metadata := stored.metadata
+	if target.Metadata != nil {
+		metadata = target.Metadata
+	}
+	m.put(target, stored.content, metadata)
+}
+
+func (m *memoryStorage) middleware(request Request, next Handler) Response {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls[request.Operation]++
+	object := request.Object
+	switch request.Operation {
+	case OperationCreateBucket:
+		return Response{}
+	case OperationBucketExists:
+		return Response{BucketStatus: BucketAccessible}
+	case OperationCanAccess:
+		return Response{Permitted: true}
+	case OperationUpload:
+		content, err := os.ReadFile(request.LocalFile)
+		checkErr(err, err)
+		m.put(object, content, object.Metadata)
+	case OperationDownload:
+		err := os.WriteFile(request.LocalFile, m.get(object).content, 0600)
+		checkErr(err, err)
+	case OperationDownloadAsReader:
+		return Response{Reader: io.NopCloser(bytes.NewReader(m.get(object).content))}
+	case OperationStatObject:
+		return Response{ObjectInfo: m.info(object.Key, m.get(object))}
+	case OperationIsModified:
+		return Response{Modified: m.info(object.Key, m.get(GoStorageObject{ProviderType: object.ProviderType,
+			Bucket: object.Bucket, Key: object.Key})).ETag != request.Cached.ETag}
+	case OperationSetObjectMetadata:
+		m.put(object, m.get(object).content, object.Metadata)
+	case OperationCopyFile:
+		m.copy(object, request.Target)
+	case OperationCopyBucket:
+		for _, key := range m.keys(object) {
+			source, target := object, request.Target
+			source.Key, target.Key = key, key
+			m.copy(source, target)
+		}
+	case OperationListFiles:
+		return Response{Keys: m.keys(object)}
+	case OperationListObjects:
+		var objects []ObjectInfo
+		for _, key := range m.keys(object) {
+			objects = append(objects, m.info(key, m.objects[memoryKey(GoStorageObject{ProviderType: object.ProviderType,
+				Bucket: object.Bucket, Key: key})]))
+		}
+		return Response{Objects: objects}
+	default:
+		checkErr(errors.New("unsupported operation"), request.Operation)
+	}
+	return Response{}
+}
@@ -0,0 +1,360 @@
+package gostorage
+
+import (
+	"fmt"
+	"io"
+)
+
+type Operation string
+
+const (
+	OperationCreateBucket                Operation = "createBucket"
+	OperationBucketExists                Operation = "bucketExists"
+	OperationListBuckets                 Operation = "listBuckets"
+	OperationDeleteBucket                Operation = "deleteBucket"
+	OperationCopyFile                    Operation = "copyFile"
+	OperationCopyBucket                  Operation = "copyBucket"
+	OperationCanAccess                   Operation = "canAccess"
+	OperationUpload                      Operation = "upload"
+	OperationDownload                    Operation = "download"
+	OperationDownloadAsReader            Operation = "downloadAsReader"
+	OperationListFiles                   Operation = "listFiles"
+	OperationListDirectory               Operation = "listDirectory"
+	OperationListObjects                 Operation = "listObjects"
+	OperationDeleteFile                  Operation = "deleteFile"
+	OperationDeleteFiles                 Operation = "deleteFiles"
+	OperationStatObject                  Operation = "statObject"
+	OperationIsModified                  Operation = "isModified"
+	OperationSetObjectMetadata           Operation = "setObjectMetadata"
+	OperationSetVersioning               Operation = "setVersioning"
+	OperationListObjectVersions          Operation = "listObjectVersions"
+	OperationRestoreObjectVersion        Operation = "restoreObjectVersion"
+	OperationGetLifecycleRules           Operation = "getLifecycleRules"
+	OperationSetLifecycleRules           Operation = "setLifecycleRules"
+	OperationDeleteLifecycleRules        Operation = "deleteLifecycleRules"
+	OperationSetObjectACL                Operation = "setObjectACL"
+	OperationSetPublicAccessBlock        Operation = "setPublicAccessBlock"
+	OperationSetUniformBucketLevelAccess Operation = "setUniformBucketLevelAccess"
+	OperationGetBucketPolicy             Operation = "getBucketPolicy"
+	OperationSetBucketPolicy             Operation = "setBucketPolicy"
+	OperationSetDefaultEncryption        Operation = "setDefaultEncryption"
+	OperationGetObjectTags               Operation = "getObjectTags"
+	OperationSetObjectTags               Operation = "setObjectTags"
+	OperationDeleteObjectTags            Operation = "deleteObjectTags"
+	OperationGetBucketTags               Operation = "getBucketTags"
+	OperationSetBucketTags               Operation = "setBucketTags"
+	OperationDeleteBucketTags            Operation = "deleteBucketTags"
+	OperationListFilesWithTags           Operation = "listFilesWithTags"
+)
+
+// Request describes a provider call, only the fields used by the Operation are set
+type Request struct {
+	Operation Operation
+	// Object the bucket or object the operation is applied to, the source of copies
+	Object GoStorageObject
+	// Target of OperationCopyFile and OperationCopyBucket
+	Target GoStorageObject
+	// Objects of OperationDeleteFiles
+	Objects []GoStorageObject
+	// LocalFile source file of OperationUpload and target file of OperationDownload
+	LocalFile        string
+	BucketOptions    BucketOptions
+	DeleteIfNotEmpty bool
+	// Enabled of OperationSetVersioning, OperationSetPublicAccessBlock and OperationSetUniformBucketLevelAccess
+	Enabled        bool
+	LifecycleRules []LifecycleRule
+	ACL            ACL
+	Policy         string
+	Encryption     Encryption
+	// Cached ETag and version of OperationIsModified
+	Cached ObjectInfo
+	// Tags of OperationSetObjectTags, OperationSetBucketTags and OperationListFilesWithTags
+	Tags map[string]string
+}
+
+// Response holds the result of a provider call, only the field matching the Operation is set
+type Response struct {
+	CreateBucketResult CreateBucketResult
+	// BucketStatus result of OperationBucketExists
+	BucketStatus BucketStatus
+	// Permitted result of OperationCanAccess
+	Permitted bool
+	// Modified result of OperationIsModified
+	Modified bool
+	Buckets  []BucketInfo
+	Reader   io.Reader
+	Keys     []string
+	// Prefixes common prefixes of OperationListDirectory
+	Prefixes      []string
+	DeleteResults []DeleteResult
+	// Objects result of OperationListObjects
+	Objects        []ObjectInfo
+	ObjectInfo     ObjectInfo
+	Versions       []ObjectVersion
+	LifecycleRules []LifecycleRule
+	Policy         string
+	// Tags result of OperationGetObjectTags and OperationGetBucketTags
+	Tags map[string]string
+}
+
+// Handler executes a provider call
+type Handler func(request Request) Response
+
+// Middleware wraps provider calls. It can inspect and modify the request before passing it to next, short-circuit the
+// call by returning a response without calling next, or post-process the response of next.
+type Middleware func(request Request, next Handler) Response
+
+// middlewareProvider passes every provider call through the middleware chain, the first middleware is the outermost one
+type middlewareProvider struct {
+	Provider
+	middleware   []Middleware
+	providerType ProviderType
+}
+
+func (p middlewareProvider) handle(request Request) Response {
+	handler := Handler(p.call)
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		middleware, next := p.middleware[i], handler
+		handler = func(request Request) Response {
+			return middleware(request, next)
+		}
+	}
+	return handler(request)
+}
+
+// call executes the request with the wrapped provider
+func (p middlewareProvider) call(request Request) Response {
+	switch request.Operation {
+	case OperationCreateBucket:
+		return Response{CreateBucketResult: p.Provider.createBucket(request.Object, request.BucketOptions)}
+	case OperationBucketExists:
+		return Response{BucketStatus: p.Provider.bucketStatus(request.Object)}
+	case OperationListBuckets:
+		return Response{Buckets: p.Provider.listBuckets()}
+	case OperationDeleteBucket:
+		p.Provider.deleteBucket(request.Object, request.DeleteIfNotEmpty)
+	case OperationCopyFile:
+		p.Provider.copyFileWithinProvider(request.Object, request.Target)
+	case OperationCopyBucket:
+		p.Provider.copyBucketWithinProvider(request.Object, request.Target)
+	case OperationCanAccess:
+		return Response{Permitted: p.Provider.canAccess(request.Object)}
+	case OperationUpload:
+		p.Provider.uploadFile(request.Object, request.LocalFile)
+	case OperationDownload:
+		p.Provider.downloadFile(request.Object, request.LocalFile)
+	case OperationDownloadAsReader:
+		return Response{Reader: p.Provider.downloadFileAsReader(request.Object)}
+	case OperationListFiles:
+		return Response{Keys: p.Provider.listFilesInBucket(request.Object)}
+	case OperationListDirectory:
+		keys, prefixes := p.Provider.listDirectory(request.Object)
+		return Response{Keys: keys, Prefixes: prefixes}
+	case OperationListObjects:
+		return Response{Objects: p.Provider.listObjects(request.Object)}
+	case OperationDeleteFile:
+		p.Provider.deleteFile(request.Object)
+	case OperationDeleteFiles:
+		return Response{DeleteResults: p.Provider.deleteFiles(request.Objects)}
+	case OperationStatObject:
+		return Response{ObjectInfo: p.Provider.statObject(request.Object)}
+	case OperationIsModified:
+		return Response{Modified: p.Provider.isModified(request.Object, request.Cached)}
+	case OperationSetObjectMetadata:
+		p.Provider.setObjectMetadata(request.Object)
+	case OperationSetVersioning:
+		p.Provider.setBucketVersioning(request.Object, request.Enabled)
+	case OperationListObjectVersions:
+		return Response{Versions: p.Provider.listObjectVersions(request.Object)}
+	case OperationRestoreObjectVersion:
+		p.Provider.restoreObjectVersion(request.Object)
+	case OperationGetLifecycleRules:
+		return Response{LifecycleRules: p.Provider.getLifecycleRules(request.Object)}
+	case OperationSetLifecycleRules:
+		p.Provider.setLifecycleRules(request.Object, request.LifecycleRules)
+	case OperationDeleteLifecycleRules:
+		p.Provider.deleteLifecycleRules(request.Object)
+	case OperationSetObjectACL:
+		p.Provider.setObjectACL(request.Object, request.ACL)
+	case OperationSetPublicAccessBlock:
+		p.Provider.setPublicAccessBlock(request.Object, request.Enabled)
+	case OperationSetUniformBucketLevelAccess:
+		p.Provider.setUniformBucketLevelAccess(request.Object, request.Enabled)
+	case OperationGetBucketPolicy:
+		return Response{Policy: p.Provider.getBucketPolicy(request.Object)}
+	case OperationSetBucketPolicy:
+		p.Provider.setBucketPolicy(request.Object, request.Policy)
+	case OperationSetDefaultEncryption:
+		p.Provider.setDefaultBucketEncryption(request.Object, request.Encryption)
+	case OperationGetObjectTags:
+		return Response{Tags: p.Provider.getObjectTags(request.Object)}
+	case OperationSetObjectTags:
+		p.Provider.setObjectTags(request.Object, request.Tags)
+	case OperationDeleteObjectTags:
+		p.Provider.deleteObjectTags(request.Object)
+	case OperationGetBucketTags:
+		return Response{Tags: p.Provider.getBucketTags(request.Object)}
+	case OperationSetBucketTags:
+		p.Provider.setBucketTags(request.Object, request.Tags)
+	case OperationDeleteBucketTags:
+		p.Provider.deleteBucketTags(request.Object)
+	case OperationListFilesWithTags:
+		return Response{Keys: p.Provider.listFilesWithTags(request.Object, request.Tags)}
+	default:
+		fail(nil, fmt.Sprintf("unknown operation %v", request.Operation))
+	}
+	return Response{}
+}
+
+func (p middlewareProvider) createBucket(target GoStorageObject, options BucketOptions) CreateBucketResult {
+	return p.handle(Request{Operation: OperationCreateBucket, Object: target, BucketOptions: options}).CreateBucketResult
+}
+
+func (p middlewareProvider) bucketStatus(target GoStorageObject) BucketStatus {
+	return p.handle(Request{Operation: OperationBucketExists, Object: target}).BucketStatus
+}
+
+func (p middlewareProvider) listBuckets() []BucketInfo {
+	return p.handle(Request{Operation: OperationListBuckets, Object: GoStorageObject{ProviderType: p.providerType}}).Buckets
+}
+
+func (p middlewareProvider) deleteBucket(target GoStorageObject, deleteIfNotEmpty bool) {
+	p.handle(Request{Operation: OperationDeleteBucket, Object: target, DeleteIfNotEmpty: deleteIfNotEmpty})
+}
+
+func (p middlewareProvider) copyFileWithinProvider(source GoStorageObject, target GoStorageObject) {
+	p.handle(Request{Operation: OperationCopyFile, Object: source, Target: target})
+}
+
+func (p middlewareProvider) copyBucketWithinProvider(source GoStorageObject, target GoStorageObject) {
+	p.handle(Request{Operation: OperationCopyBucket, Object: source, Target: target})
+}
+
+func (p middlewareProvider) canAccess(source GoStorageObject) bool {
+	return p.handle(Request{Operation: OperationCanAccess, Object: source}).Permitted
+}
+
+func (p middlewareProvider) uploadFile(target GoStorageObject, sourceFile string) {
+	p.handle(Request{Operation: OperationUpload, Object: target, LocalFile: sourceFile})
+}
+
+func (p middlewareProvider) downloadFile(source GoStorageObject, targetFile string) {
+	p.handle(Request{Operation: OperationDownload, Object: source, LocalFile: targetFile})
+}
+
+func (p middlewareProvider) downloadFileAsReader(source GoStorageObject) io.Reader {
+	return p.handle(Request{Operation: OperationDownloadAsReader, Object: source}).Reader
+}
+
+func (p middlewareProvider) listFilesInBucket(source GoStorageObject) []string {
+	return p.handle(Request{Operation: OperationListFiles, Object: source}).Keys
+}
+
+func (p middlewareProvider) listDirectory(source GoStorageObject) ([]string, []string) {
+	response := p.handle(Request{Operation: OperationListDirectory, Object: source})
+	return response.Keys, response.Prefixes
+}
+
+func (p middlewareProvider) listObjects(source GoStorageObject) []ObjectInfo {
+	return p.handle(Request{Operation: OperationListObjects, Object: source}).Objects
+}
+
+func (p middlewareProvider) deleteFile(target GoStorageObject) {
+	p.handle(Request{Operation: OperationDeleteFile, Object: target})
+}
+
+func (p middlewareProvider) deleteFiles(targets []GoStorageObject) []DeleteResult {
+	request := Request{Operation: OperationDeleteFiles, Objects: targets}
+	if len(targets) > 0 {
+		request.Object = GoStorageObject{Bucket: targets[0].Bucket, Region: targets[0].Region, ProviderType: targets[0].ProviderType, Account: targets[0].Account}
+	}
+	return p.handle(request).DeleteResults
+}
+
+func (p middlewareProvider) statObject(source GoStorageObject) ObjectInfo {
+	return p.handle(Request{Operation: OperationStatObject, Object: source}).ObjectInfo
+}
+
+func (p middlewareProvider) isModified(source GoStorageObject, cached ObjectInfo) bool {
+	return p.handle(Request{Operation: OperationIsModified, Object: source, Cached: cached}).Modified
+}
+
+func (p middlewareProvider) setObjectMetadata(target GoStorageObject) {
+	p.handle(Request{Operation: OperationSetObjectMetadata, Object: target})
+}
+
+func (p middlewareProvider) setBucketVersioning(target GoStorageObject, enabled bool) {
+	p.handle(Request{Operation: OperationSetVersioning, Object: target, Enabled: enabled})
+}
+
+func (p middlewareProvider) listObjectVersions(source GoStorageObject) []ObjectVersion {
+	return p.handle(Request{Operation: OperationListObjectVersions, Object: source}).Versions
+}
+
+func (p middlewareProvider) restoreObjectVersion(source GoStorageObject) {
+	p.handle(Request{Operation: OperationRestoreObjectVersion, Object: source})
+}
+
+func (p middlewareProvider) getLifecycleRules(target GoStorageObject) []LifecycleRule {
+	return p.handle(Request{Operation: OperationGetLifecycleRules, Object: target}).LifecycleRules
+}
+
+func (p middlewareProvider) setLifecycleRules(target GoStorageObject, rules []LifecycleRule) {
+	p.handle(Request{Operation: OperationSetLifecycleRules, Object: target, LifecycleRules: rules})
+}
+
+func (p middlewareProvider) deleteLifecycleRules(target GoStorageObject) {
+	p.handle(Request{Operation: OperationDeleteLifecycleRules, Object: target})
+}
+
+func (p middlewareProvider) setObjectACL(target GoStorageObject, acl ACL) {
+	p.handle(Request{Operation: OperationSetObjectACL, Object: target, ACL: acl})
+}
+
+func (p middlewareProvider) setPublicAccessBlock(target GoStorageObject, blocked bool) {
+	p.handle(Request{Operation: OperationSetPublicAccessBlock, Object: target, Enabled: blocked})
+}
+
+func (p middlewareProvider) setUniformBucketLevelAccess(target GoStorageObject, enabled bool) {
+	p.handle(Request{Operation: OperationSetUniformBucketLevelAccess, Object: target, Enabled: enabled})
+}
+
+func (p middlewareProvider) getBucketPolicy(target GoStorageObject) string {
+	return p.handle(Request{Operation: OperationGetBucketPolicy, Object: target}).Policy
+}
+
+func (p middlewareProvider) setBucketPolicy(target GoStorageObject, policy string) {
+	p.handle(Request{Operation: OperationSetBucketPolicy, Object: target, Policy: policy})
+}
+
+func (p middlewareProvider) setDefaultBucketEncryption(target GoStorageObject, encryption Encryption) {
+	p.handle(Request{Operation: OperationSetDefaultEncryption, Object: target, Encryption: encryption})
+}
+
+func (p middlewareProvider) getObjectTags(target GoStorageObject) map[string]string {
+	return p.handle(Request{Operation: OperationGetObjectTags, Object: target}).Tags
+}
+
+func (p middlewareProvider) setObjectTags(target GoStorageObject, tags map[string]string) {
+	p.handle(Request{Operation: OperationSetObjectTags, Object: target, Tags: tags})
+}
+
+func (p middlewareProvider) deleteObjectTags(target GoStorageObject) {
+	p.handle(Request{Operation: OperationDeleteObjectTags, Object: target})
+}
+
+func (p middlewareProvider) getBucketTags(target GoStorageObject) map[string]string {
+	return p.handle(Request{Operation: OperationGetBucketTags, Object: target}).Tags
+}
+
+func (p middlewareProvider) setBucketTags(target GoStorageObject, tags map[string]string) {
+	p.handle(Request{Operation: OperationSetBucketTags, Object: target, Tags: tags})
+}
+
+func (p middlewareProvider) deleteBucketTags(target GoStorageObject) {
+	p.handle(Request{Operation: OperationDeleteBucketTags, Object: target})
+}
+
+func (p middlewareProvider) listFilesWithTags(source GoStorageObject, tags map[string]string) []string {
+	return p.handle(Request{Operation: OperationListFilesWithTags, Object: source, Tags: tags}).Keys
+}
@@ -0,0 +1,129 @@
+package gostorage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveResult lists the keys (of the source) which were moved and the keys which could not be moved. Sources of failed
+// keys are kept. A source bucket which could not be deleted after moving its objects is reported with the empty key.
+type MoveResult struct {
+	Moved  []string
+	Failed map[string]error
+}
+
+// Succeeded returns true if all objects were moved
+func (r MoveResult) Succeeded() bool {
+	return len(r.Failed) == 0
+}
+
+// Move moves single objects, prefixes (source and target keys ending with "/") or whole buckets (empty source and target
+// keys) within and across providers. Objects are copied server-side if possible and the source is deleted only after
+// the copy was verified. Failed copies and deletes do not abort the move, they are reported in MoveResult.Failed.
+// Moved buckets are deleted if all of their objects were moved and no other objects or versions remain.
+func (s GoStorage) Move(source GoStorageObject, target GoStorageObject) MoveResult {
+	s, span := s.startSpan("Move", source)
+	defer span.End()
+	if source.IsLocal || target.IsLocal {
+		fail(nil, "Move is only supported between storage objects")
+	}
+	result := MoveResult{Failed: map[string]error{}}
+
+	switch {
+	case source.Key == "" && target.Key == "":
+		s.moveKeys(source, target, s.listKeys(source), &result)
+		if result.Succeeded() {
+			s.deleteMovedBucket(source, &result)
+		}
+	case strings.HasSuffix(source.Key, "/") && strings.HasSuffix(target.Key, "/"):
+		s.moveKeys(source, target, s.listKeys(source), &result)
+	case source.Key != "" && target.Key != "" && !strings.HasSuffix(source.Key, "/"):
+		s.ensureBucket(target)
+		s.moveObject(source, target, &result)
+	default:
+		fail(nil, "Incorrect configuration of source and target key found")
+	}
+	if !result.Succeeded() {
+		setSpanError(span, fmt.Errorf("%v of %v objects could not be moved", len(result.Failed), len(result.Failed)+len(result.Moved)))
+	}
+	return result
+}
+
+// listKeys lists the keys of all objects starting with source.Key
+func (s GoStorage) listKeys(source GoStorageObject) []string {
+	var keys []string
+	for _, info := range s.provider(source).listObjects(source) {
+		keys = append(keys, info.Key)
+	}
+	return keys
+}
+
+// moveKeys moves all keys of the source bucket, the source prefix of the keys is replaced with the target prefix
+func (s GoStorage) moveKeys(source GoStorageObject, target GoStorageObject, keys []string, result *MoveResult) {
+	s.ensureBucket(target)
+	s.CopyOptions.BucketCreation = BucketCreationNever
+	sourcePrefix, targetPrefix := source.Key, target.Key
+	for _, key := range keys {
+		source.Key = key
+		target.Key = targetPrefix + strings.TrimPrefix(key, sourcePrefix)
+		s.moveObject(source, target, result)
+	}
+}
+
+func (s GoStorage) moveObject(source GoStorageObject, target GoStorageObject, result *MoveResult) {
+	if source.ProviderType == target.ProviderType && source.Account == target.Account &&
+		source.Bucket == target.Bucket && source.Key == target.Key {
+		result.Failed[source.Key] = fmt.Errorf("source and target of %v are the same object", source.Key)
+		return
+	}
+	if err := catchErrors(func() { s.Copy(source, target) }); err != nil {
+		result.Failed[source.Key] = err
+		return
+	}
+	if err := s.verifyCopy(source, target); err != nil {
+		result.Failed[source.Key] = err
+		return
+	}
+	if err := catchErrors(func() { s.provider(source).deleteFile(source) }); err != nil {
+		result.Failed[source.Key] = fmt.Errorf("%v was copied but could not be deleted: %w", source.Key, err)
+		return
+	}
+	result.Moved = append(result.Moved, source.Key)
+}
+
+// deleteMovedBucket deletes the source bucket of a move, it fails if objects (e.g. added during the move) or versions remain
+func (s GoStorage) deleteMovedBucket(source GoStorageObject, result *MoveResult) {
+	bucket := GoStorageObject{Bucket: source.Bucket, Region: source.Region, ProviderType: source.ProviderType, Account: source.Account}
+	var versions []ObjectVersion
+	err := catchErrors(func() { versions = s.provider(bucket).listObjectVersions(bucket) })
+	if err == nil && len(versions) > 0 {
+		err = fmt.Errorf("bucket %v is not empty after the move, %v objects or versions remain", bucket.Bucket, len(versions))
+	}
+	if err == nil {
+		err = catchErrors(func() { s.provider(bucket).deleteBucket(bucket, false) })
+	}
+	if err != nil {
+		result.Failed[""] = err
+		return
+	}
+	s.bucketCache().remove(bucket)
+}
+
+// verifyCopy checks that target exists and has the same size as source
+func (s GoStorage) verifyCopy(source GoStorageObject, target GoStorageObject) error {
+	target.VersionId = ""
+	if !s.provider(target).canAccess(target) {
+		return fmt.Errorf("copy of %v was not found at %v", source.Key, target.Key)
+	}
+	var sourceInfo, targetInfo ObjectInfo
+	err := catchErrors(func() {
+		sourceInfo, targetInfo = s.provider(source).statObject(source), s.provider(target).statObject(target)
+	})
+	if err != nil {
+		return err
+	}
+	if sourceInfo.Size != targetInfo.Size {
+		return fmt.Errorf("copy of %v has size %v, expected %v", source.Key, targetInfo.Size, sourceInfo.Size)
+	}
+	return nil
+}
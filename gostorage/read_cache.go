@@ -0,0 +1,293 @@
+package gostorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReadCache stores downloaded objects on local disk. Cached objects are revalidated with a conditional request and are
+// only downloaded again if they have changed, objects with a VersionId are never revalidated. The least recently used
+// objects are evicted when MaxSize is exceeded. Objects are cached as they are stored, so client-side encrypted objects
+// are only decrypted when they are read. The index of the cache is kept in the directory and is locked while it is
+// updated, so the cache can be shared by several processes.
+type ReadCache struct {
+	// Directory defaults to a gostorage-cache directory in the temporary directory
+	Directory string
+	// MaxSize in bytes, defaults to DefaultReadCacheSize
+	MaxSize int64
+
+	mutex sync.Mutex
+}
+
+type readCacheEntry struct {
+	File         string
+	ETag         string
+	VersionId    string
+	Size         int64
+	LastModified time.Time
+	// Metadata of the object, entries without metadata were written by older versions and are discarded
+	Metadata map[string]string
+	LastUsed time.Time
+}
+
+const readCacheIndexFile = "index.json"
+const readCacheLockFile = "index.lock"
+
+// readCacheStaleLock lock files older than this are left over by crashed processes and are removed
+const readCacheStaleLock = 30 * time.Second
+
+func (c *ReadCache) directory() string {
+	if c.Directory != "" {
+		return c.Directory
+	}
+	return filepath.Join(os.TempDir(), "gostorage-cache")
+}
+
+func (c *ReadCache) maxSize() int64 {
+	if c.MaxSize > 0 {
+		return c.MaxSize
+	}
+	return DefaultReadCacheSize
+}
+
+// readProvider returns the provider used by DownloadFile and DownloadFileAsReader. With a ReadCache the stored objects are
+// cached below the client-side encryption and compression, which are applied on every read. The cache also answers
+// their statObject calls, so a read revalidates the object once and makes no other request if it is cached.
+func (s GoStorage) readProvider(storageObject GoStorageObject) Provider {
+	if s.ReadCache == nil {
+		return s.provider(storageObject)
+	}
+	var provider Provider = readCacheProvider{Provider: s.baseProvider(storageObject), cache: s.ReadCache,
+		validated: map[string]readCacheEntry{}}
+	if s.ClientSideEncryption != nil {
+		provider = s.ClientSideEncryption.Wrap(provider)
+	}
+	if s.Compression != nil {
+		provider = s.Compression.Wrap(provider)
+	}
+	return provider
+}
+
+// readCacheProvider serves downloads and the info of the downloaded objects from the cache
+type readCacheProvider struct {
+	Provider
+	cache *ReadCache
+	// validated entries of this read by cache key, they are not revalidated again
+	validated map[string]readCacheEntry
+}
+
+func (p readCacheProvider) downloadFile(source GoStorageObject, targetFile string) {
+	copyLocalFile(p.cachedFile(source), targetFile)
+}
+
+func (p readCacheProvider) downloadFileAsReader(source GoStorageObject) io.Reader {
+	file, err := os.Open(p.cachedFile(source))
+	if errors.Is(err, os.ErrNotExist) {
+		// evicted by another process in the meantime
+		delete(p.validated, readCacheKey(source))
+		file, err = os.Open(p.cachedFile(source))
+	}
+	checkErr(err, fmt.Sprintf("unable to read cached storage object %v, Error: %v", source.Key, err))
+	return file
+}
+
+// statObject returns the info of the cached object, the object is downloaded if it is not cached or has changed
+func (p readCacheProvider) statObject(source GoStorageObject) ObjectInfo {
+	entry := p.cachedEntry(source)
+	return ObjectInfo{Key: source.Key, ETag: entry.ETag, VersionId: entry.VersionId, Size: entry.Size,
+		LastModified: entry.LastModified, Metadata: entry.Metadata}
+}
+
+func (p readCacheProvider) cachedFile(source GoStorageObject) string {
+	return filepath.Join(p.cache.directory(), p.cachedEntry(source).File)
+}
+
+// cachedEntry returns the cache entry of source, validated once per read. Reads of the validated version (as requested
+// by the wrappers above the cache) use the same entry.
+func (p readCacheProvider) cachedEntry(source GoStorageObject) readCacheEntry {
+	cacheKey := readCacheKey(source)
+	if entry, ok := p.validated[cacheKey]; ok {
+		return entry
+	}
+	entry := p.validate(source, cacheKey)
+	p.validated[cacheKey] = entry
+	if source.VersionId == "" && entry.VersionId != "" {
+		source.VersionId = entry.VersionId
+		p.validated[readCacheKey(source)] = entry
+	}
+	return entry
+}
+
+// validate returns the cache entry of source, the object is downloaded if it is not cached or has changed
+func (p readCacheProvider) validate(source GoStorageObject, cacheKey string) readCacheEntry {
+	cache := p.cache
+	if entry, ok := cache.get(cacheKey); ok {
+		cached := ObjectInfo{ETag: entry.ETag, VersionId: entry.VersionId}
+		if source.VersionId != "" || !p.isModified(source, cached) {
+			return entry
+		}
+	}
+
+	info := p.Provider.statObject(source)
+	err := os.MkdirAll(cache.directory(), 0700)
+	checkErr(err, fmt.Sprintf("unable to create cache directory %v, Error: %v", cache.directory(), err))
+	tempFile, err := os.CreateTemp(cache.directory(), "download")
+	checkErr(err, fmt.Sprintf("unable to create temporary file, Error: %v", err))
+	tempFilePath := getAbsolutePath(tempFile)
+	err = tempFile.Close()
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", tempFilePath, err))
+
+	if source.VersionId == "" {
+		source.VersionId = info.VersionId
+	}
+	p.Provider.downloadFile(source, tempFilePath)
+	return cache.put(cacheKey, tempFilePath, info)
+}
+
+func (c *ReadCache) get(cacheKey string) (readCacheEntry, bool) {
+	var entry readCacheEntry
+	var ok bool
+	c.update(func(entries map[string]*readCacheEntry) {
+		var current *readCacheEntry
+		current, ok = entries[cacheKey]
+		if !ok {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(c.directory(), current.File)); err != nil || current.Metadata == nil {
+			os.Remove(filepath.Join(c.directory(), current.File))
+			delete(entries, cacheKey)
+			ok = false
+			return
+		}
+		current.LastUsed = time.Now()
+		entry = *current
+	})
+	return entry, ok
+}
+
+// put moves the downloaded file into the cache and evicts the least recently used entries if the cache is too large
+func (c *ReadCache) put(cacheKey string, downloadedFile string, info ObjectInfo) readCacheEntry {
+	entry := &readCacheEntry{File: cacheFileName(cacheKey), ETag: info.ETag, VersionId: info.VersionId,
+		Size: fileSize(downloadedFile), LastModified: info.LastModified, Metadata: info.Metadata, LastUsed: time.Now()}
+	if entry.Metadata == nil {
+		entry.Metadata = map[string]string{}
+	}
+	filePath := filepath.Join(c.directory(), entry.File)
+	c.update(func(entries map[string]*readCacheEntry) {
+		err := os.Rename(downloadedFile, filePath)
+		checkErr(err, fmt.Sprintf("unable to move %v into the cache, Error: %v", downloadedFile, err))
+		entries[cacheKey] = entry
+		c.evict(entries, cacheKey)
+	})
+	return *entry
+}
+
+// update reads the index, applies change and writes the index back, while holding the lock of the index
+func (c *ReadCache) update(change func(entries map[string]*readCacheEntry)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	err := os.MkdirAll(c.directory(), 0700)
+	checkErr(err, fmt.Sprintf("unable to create cache directory %v, Error: %v", c.directory(), err))
+	unlock := c.lockIndex()
+	defer unlock()
+	entries := c.load()
+	change(entries)
+	c.save(entries)
+}
+
+// lockIndex creates the lock file of the index, waiting while another process holds it
+func (c *ReadCache) lockIndex() func() {
+	lockFile := filepath.Join(c.directory(), readCacheLockFile)
+	for {
+		file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockFile) }
+		}
+		if !errors.Is(err, os.ErrExist) {
+			checkErr(err, fmt.Sprintf("unable to lock cache index %v, Error: %v", lockFile, err))
+		}
+		if info, err := os.Stat(lockFile); err == nil && time.Since(info.ModTime()) > readCacheStaleLock {
+			os.Remove(lockFile)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// evict removes the least recently used entries until the cache fits into MaxSize, keep is never removed
+func (c *ReadCache) evict(entries map[string]*readCacheEntry, keep string) {
+	var size int64
+	cacheKeys := make([]string, 0, len(entries))
+	for cacheKey, entry := range entries {
+		size += entry.Size
+		cacheKeys = append(cacheKeys, cacheKey)
+	}
+	sort.Slice(cacheKeys, func(i, j int) bool {
+		return entries[cacheKeys[i]].LastUsed.Before(entries[cacheKeys[j]].LastUsed)
+	})
+	for _, cacheKey := range cacheKeys {
+		if size <= c.maxSize() {
+			return
+		}
+		if cacheKey == keep {
+			continue
+		}
+		os.Remove(filepath.Join(c.directory(), entries[cacheKey].File))
+		size -= entries[cacheKey].Size
+		delete(entries, cacheKey)
+	}
+}
+
+// load reads the index of the cache directory, a missing or broken index results in an empty cache
+func (c *ReadCache) load() map[string]*readCacheEntry {
+	entries := map[string]*readCacheEntry{}
+	data, err := os.ReadFile(filepath.Join(c.directory(), readCacheIndexFile))
+	if err == nil {
+		if json.Unmarshal(data, &entries) != nil {
+			entries = map[string]*readCacheEntry{}
+		}
+	}
+	return entries
+}
+
+func (c *ReadCache) save(entries map[string]*readCacheEntry) {
+	data, err := json.Marshal(entries)
+	checkErr(err, fmt.Sprintf("unable to write cache index, Error: %v", err))
+	indexFile := filepath.Join(c.directory(), readCacheIndexFile)
+	err = os.WriteFile(indexFile+".tmp", data, 0600)
+	if err == nil {
+		err = os.Rename(indexFile+".tmp", indexFile)
+	}
+	checkErr(err, fmt.Sprintf("unable to write cache index %v, Error: %v", indexFile, err))
+}
+
+func readCacheKey(source GoStorageObject) string {
+	return bucketCacheKey(source) + "/" + source.Key + "@" + source.VersionId
+}
+
+func cacheFileName(cacheKey string) string {
+	hash := sha256.Sum256([]byte(cacheKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// copyLocalFile copies the local file sourceFile to targetFile
+func copyLocalFile(sourceFile string, targetFile string) {
+	source, err := os.Open(sourceFile)
+	checkErr(err, fmt.Sprintf("unable to read file content from %v, Error: %v", sourceFile, err))
+	defer source.Close()
+	target, err := os.Create(targetFile)
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", targetFile, err))
+	defer target.Close()
+	_, err = io.Copy(target, source)
+	checkErr(err, fmt.Sprintf("unable to write to file %v, Error: %v", targetFile, err))
+}
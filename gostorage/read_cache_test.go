@@ -0,0 +1,45 @@
+package gostorage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadCacheWithEncryptionAndCompression(t *testing.T) {
+	memory := newMemoryStorage()
+	s := GoStorage{Middleware: []Middleware{memory.middleware}, ReadCache: &ReadCache{Directory: t.TempDir()},
+		ClientSideEncryption: &EnvelopeEncryption{KeyEncryptionKey: newTestKeyEncryptionKey(t)},
+		Compression:          &Compression{Algorithm: CompressionGzip},
+		CopyOptions:          CopyOptions{BucketCreation: BucketCreationNever}}
+	object := GoStorageObject{Bucket: "bucket", Key: "file.txt", ProviderType: ProviderAWS}
+	read := func() string {
+		file := filepath.Join(t.TempDir(), "download")
+		s.DownloadFile(object, file)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	content := strings.Repeat("cached content ", 100)
+	upload(t, s, object, content)
+	if got := read(); got != content {
+		t.Fatal("first read returned other content")
+	}
+	memory.calls = map[Operation]int{}
+	if got := read(); got != content {
+		t.Fatal("cached read returned other content")
+	}
+	if memory.calls[OperationStatObject] != 0 || memory.calls[OperationDownload] != 0 || memory.calls[OperationIsModified] != 1 {
+		t.Errorf("cached read made the requests %v, expected a single revalidation", memory.calls)
+	}
+
+	changed := strings.Repeat("changed content ", 100)
+	upload(t, s, object, changed)
+	if got := read(); got != changed {
+		t.Error("changed object was not downloaded again")
+	}
+}
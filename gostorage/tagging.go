@@ -0,0 +1,109 @@
+package gostorage
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// GetObjectTags returns the S3 object tags or the tags stored as custom metadata of Google objects respectively
+func (s GoStorage) GetObjectTags(object GoStorageObject) map[string]string {
+	s, span := s.startSpan("GetObjectTags", object)
+	defer span.End()
+	return s.provider(object).getObjectTags(object)
+}
+
+// SetObjectTags replaces the tags of the object. On Google the tags are stored as custom metadata with the key prefix
+// GoogleTagMetadataPrefix, other metadata is kept.
+func (s GoStorage) SetObjectTags(object GoStorageObject, tags map[string]string) {
+	s, span := s.startSpan("SetObjectTags", object)
+	defer span.End()
+	s.provider(object).setObjectTags(object, tags)
+}
+
+func (s GoStorage) DeleteObjectTags(object GoStorageObject) {
+	s, span := s.startSpan("DeleteObjectTags", object)
+	defer span.End()
+	s.provider(object).deleteObjectTags(object)
+}
+
+// GetBucketTags returns the AWS bucket tags or Google bucket labels respectively
+func (s GoStorage) GetBucketTags(bucket GoStorageObject) map[string]string {
+	s, span := s.startSpan("GetBucketTags", bucket)
+	defer span.End()
+	return s.provider(bucket).getBucketTags(bucket)
+}
+
+// SetBucketTags replaces the tags (labels on Google) of the bucket
+func (s GoStorage) SetBucketTags(bucket GoStorageObject, tags map[string]string) {
+	s, span := s.startSpan("SetBucketTags", bucket)
+	defer span.End()
+	s.provider(bucket).setBucketTags(bucket, tags)
+}
+
+func (s GoStorage) DeleteBucketTags(bucket GoStorageObject) {
+	s, span := s.startSpan("DeleteBucketTags", bucket)
+	defer span.End()
+	s.provider(bucket).deleteBucketTags(bucket)
+}
+
+// ListFilesWithTags lists the keys of all objects starting with prefix.Key which have all of the given tags.
+// On AWS the tags of every listed object are requested separately.
+func (s GoStorage) ListFilesWithTags(prefix GoStorageObject, tags map[string]string) []string {
+	s, span := s.startSpan("ListFilesWithTags", prefix)
+	defer span.End()
+	return s.provider(prefix).listFilesWithTags(prefix, tags)
+}
+
+// hasTags checks whether objectTags contain all of tags
+func hasTags(objectTags map[string]string, tags map[string]string) bool {
+	for k, v := range tags {
+		if value, ok := objectTags[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// googleTags returns the tags stored in the metadata of a Google object
+func googleTags(metadata map[string]string) map[string]string {
+	tags := map[string]string{}
+	for k, v := range metadata {
+		if strings.HasPrefix(k, GoogleTagMetadataPrefix) {
+			tags[strings.TrimPrefix(k, GoogleTagMetadataPrefix)] = v
+		}
+	}
+	return tags
+}
+
+// withGoogleTags returns a copy of metadata whose tags are replaced by tags, all other metadata is kept
+func withGoogleTags(metadata map[string]string, tags map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range metadata {
+		if !strings.HasPrefix(k, GoogleTagMetadataPrefix) {
+			merged[k] = v
+		}
+	}
+	for k, v := range tags {
+		merged[GoogleTagMetadataPrefix+k] = v
+	}
+	return merged
+}
+
+// encodeTags encodes the tags as URL query, as expected by the S3 Tagging header
+func encodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
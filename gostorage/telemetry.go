@@ -0,0 +1,117 @@
+package gostorage
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/FaaSTools/GoStorage/gostorage"
+
+// Telemetry creates OpenTelemetry spans for all GoStorage and provider operations and records the metrics
+// gostorage.requests, gostorage.errors, gostorage.duration and gostorage.bytes by provider and operation
+type Telemetry struct {
+	// TracerProvider defaults to the global tracer provider
+	TracerProvider trace.TracerProvider
+	// MeterProvider defaults to the global meter provider
+	MeterProvider metric.MeterProvider
+
+	once     sync.Once
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+	bytes    metric.Int64Counter
+}
+
+func (t *Telemetry) init() {
+	t.once.Do(func() {
+		tracerProvider, meterProvider := t.TracerProvider, t.MeterProvider
+		if tracerProvider == nil {
+			tracerProvider = otel.GetTracerProvider()
+		}
+		if meterProvider == nil {
+			meterProvider = otel.GetMeterProvider()
+		}
+		t.tracer = tracerProvider.Tracer(instrumentationName)
+		meter := meterProvider.Meter(instrumentationName)
+
+		var err error
+		t.requests, err = meter.Int64Counter("gostorage.requests", metric.WithDescription("Number of provider operations"))
+		handleTelemetryError(err)
+		t.errors, err = meter.Int64Counter("gostorage.errors", metric.WithDescription("Number of failed provider operations"))
+		handleTelemetryError(err)
+		t.duration, err = meter.Float64Histogram("gostorage.duration", metric.WithDescription("Duration of provider operations"), metric.WithUnit("s"))
+		handleTelemetryError(err)
+		t.bytes, err = meter.Int64Counter("gostorage.bytes", metric.WithDescription("Bytes transferred by provider operations"), metric.WithUnit("By"))
+		handleTelemetryError(err)
+	})
+}
+
+// handleTelemetryError passes errors to the OpenTelemetry error handler, instruments are no-ops in this case
+func handleTelemetryError(err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// startSpan starts a span as child of the span in ctx
+func (t *Telemetry) startSpan(ctx context.Context, name string, object GoStorageObject) (context.Context, trace.Span) {
+	t.init()
+	return t.tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("gostorage.provider", string(object.ProviderType)),
+		attribute.String("gostorage.bucket", object.Bucket),
+		attribute.String("gostorage.key", object.Key),
+	))
+}
+
+// record records the metrics of a finished provider operation
+func (t *Telemetry) record(ctx context.Context, providerType ProviderType, operation string, bytes int64, seconds float64, err error) {
+	t.init()
+	attributes := metric.WithAttributes(attribute.String("gostorage.provider", string(providerType)), attribute.String("gostorage.operation", operation))
+	t.requests.Add(ctx, 1, attributes)
+	t.duration.Record(ctx, seconds, attributes)
+	if bytes > 0 {
+		t.bytes.Add(ctx, bytes, attributes)
+	}
+	if err != nil {
+		t.errors.Add(ctx, 1, attributes)
+	}
+}
+
+// WithContext returns a copy of s whose spans are children of the span in ctx
+func (s GoStorage) WithContext(ctx context.Context) GoStorage {
+	s.ctx = ctx
+	return s
+}
+
+func (s GoStorage) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// startSpan starts a span for a GoStorage operation, the returned copy of s creates child spans. Without Telemetry a
+// non-recording span is returned.
+func (s GoStorage) startSpan(operation string, object GoStorageObject) (GoStorage, trace.Span) {
+	if s.Telemetry == nil {
+		return s, trace.SpanFromContext(context.Background())
+	}
+	var span trace.Span
+	s.ctx, span = s.Telemetry.startSpan(s.context(), "GoStorage."+operation, object)
+	return s, span
+}
+
+// setSpanError marks span as failed if err is not nil
+func setSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,178 @@
+package gostorage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type URLStyle string
+
+const (
+	// URLStyleNative s3://bucket/key or gs://bucket/key
+	URLStyleNative URLStyle = "native"
+	// URLStyleVirtualHosted https://bucket.s3.region.amazonaws.com/key or https://bucket.storage.googleapis.com/key
+	URLStyleVirtualHosted URLStyle = "virtual-hosted"
+	// URLStylePath https://s3.region.amazonaws.com/bucket/key or https://storage.googleapis.com/bucket/key
+	URLStylePath URLStyle = "path"
+)
+
+const awsHostSuffix = ".amazonaws.com"
+
+// ParseURL parses Object/Bucket URLs from AWS and Google to extract information such as bucketName, key, region etc.
+// Supported are s3:// and gs:// URLs, virtual-hosted and path-style AWS URLs (incl. dash-style regions and dual-stack hosts)
+// and storage.googleapis.com/storage.cloud.google.com URLs. Strings without scheme or with file:// are treated as local files.
+func ParseURL(urlString string) (GoStorageObject, error) {
+	if !strings.Contains(urlString, "://") {
+		return GoStorageObject{IsLocal: true, LocalFilePath: urlString}, nil
+	}
+	parsedUrl, err := url.Parse(urlString)
+	if err != nil {
+		return GoStorageObject{}, err
+	}
+	host := strings.ToLower(parsedUrl.Hostname())
+
+	switch strings.ToLower(parsedUrl.Scheme) {
+	case "file":
+		return GoStorageObject{IsLocal: true, LocalFilePath: parsedUrl.Path}, nil
+	case "s3":
+		return newNativeStorageObject(ProviderAWS, parsedUrl)
+	case "gs":
+		return newNativeStorageObject(ProviderGoogle, parsedUrl)
+	case "http", "https":
+		if strings.HasSuffix(host, awsHostSuffix) {
+			return parseAWSHost(host, parsedUrl.Path)
+		}
+		return parseGoogleHost(host, parsedUrl.Path)
+	default:
+		return GoStorageObject{}, fmt.Errorf("unsupported URL scheme %v", parsedUrl.Scheme)
+	}
+}
+
+// URL formats the object as URL of the given style, so it can be parsed by ParseURL again. Local objects return their file path.
+func (receiver GoStorageObject) URL(style URLStyle) string {
+	if receiver.IsLocal {
+		return receiver.LocalFilePath
+	}
+	key := escapeKey(receiver.Key)
+
+	switch receiver.ProviderType {
+	case ProviderAWS:
+		awsHost := "s3.amazonaws.com"
+		if receiver.Region != "" {
+			awsHost = fmt.Sprintf("s3.%v.amazonaws.com", receiver.Region)
+		}
+		switch style {
+		case URLStyleVirtualHosted:
+			return fmt.Sprintf("https://%v.%v/%v", receiver.Bucket, awsHost, key)
+		case URLStylePath:
+			return fmt.Sprintf("https://%v/%v/%v", awsHost, receiver.Bucket, key)
+		default:
+			return fmt.Sprintf("s3://%v/%v", receiver.Bucket, key)
+		}
+	case ProviderGoogle:
+		switch style {
+		case URLStyleVirtualHosted:
+			return fmt.Sprintf("https://%v.storage.googleapis.com/%v", receiver.Bucket, key)
+		case URLStylePath:
+			return fmt.Sprintf("https://storage.googleapis.com/%v/%v", receiver.Bucket, key)
+		default:
+			return fmt.Sprintf("gs://%v/%v", receiver.Bucket, key)
+		}
+	default:
+		return ""
+	}
+}
+
+// parseAWSHost AWS Object URL: https://gostorage-bucket-test.s3.amazonaws.com/newfile.png,
+// https://gostorage-bucket-test.s3-us-west-2.amazonaws.com/newfile.png, https://s3.dualstack.us-west-2.amazonaws.com/gostorage-bucket-test/newfile.png
+func parseAWSHost(host string, path string) (GoStorageObject, error) {
+	labels := strings.Split(strings.TrimSuffix(host, awsHostSuffix), ".")
+	s3Label := -1
+	for i, label := range labels {
+		if label == "s3" || strings.HasPrefix(label, "s3-") {
+			s3Label = i
+		}
+	}
+	if s3Label == -1 {
+		return GoStorageObject{}, fmt.Errorf("%v is not an S3 host", host)
+	}
+
+	region := ""
+	if strings.HasPrefix(labels[s3Label], "s3-") {
+		region = strings.TrimPrefix(labels[s3Label], "s3-")
+	}
+	for _, label := range labels[s3Label+1:] {
+		if label != "dualstack" {
+			region = label
+		}
+	}
+	if region == "" || region == "external-1" {
+		region = DefaultAWSRegion
+	}
+
+	bucket := strings.Join(labels[:s3Label], ".")
+	return newStorageObjectFromPath(ProviderAWS, bucket, region, path)
+}
+
+// parseGoogleHost Google Object URL: https://storage.cloud.google.com/gostorage-bucket-test/test.png,
+// https://storage.googleapis.com/gostorage-bucket-test/test.png, https://gostorage-bucket-test.storage.googleapis.com/test.png
+func parseGoogleHost(host string, path string) (GoStorageObject, error) {
+	switch {
+	case host == "storage.cloud.google.com" || host == "storage.googleapis.com":
+		if strings.HasPrefix(path, "/storage/v1/b/") {
+			return parseGoogleJsonApiPath(path)
+		}
+		return newStorageObjectFromPath(ProviderGoogle, "", "", path)
+	case strings.HasSuffix(host, ".storage.googleapis.com"):
+		return newStorageObjectFromPath(ProviderGoogle, strings.TrimSuffix(host, ".storage.googleapis.com"), "", path)
+	default:
+		return GoStorageObject{}, fmt.Errorf("%v is neither an AWS nor a Google storage host", host)
+	}
+}
+
+// parseGoogleJsonApiPath Google JSON API URL: https://storage.googleapis.com/storage/v1/b/gostorage-bucket-test/o/test.png
+func parseGoogleJsonApiPath(path string) (GoStorageObject, error) {
+	path = strings.TrimPrefix(path, "/storage/v1/b/")
+	bucket, key := path, ""
+	if i := strings.Index(path, "/o/"); i != -1 {
+		bucket, key = path[:i], path[i+len("/o/"):]
+	}
+	return newStorageObject(ProviderGoogle, bucket, key, "")
+}
+
+// newNativeStorageObject s3:// and gs:// URLs always carry the bucket as host, the path is the key
+func newNativeStorageObject(providerType ProviderType, parsedUrl *url.URL) (GoStorageObject, error) {
+	return newStorageObject(providerType, parsedUrl.Host, strings.TrimPrefix(parsedUrl.Path, "/"), "")
+}
+
+// newStorageObjectFromPath creates the storage object from the (already unescaped) URL path, the bucket is taken from
+// the first path segment if it is not given
+func newStorageObjectFromPath(providerType ProviderType, bucket string, region string, path string) (GoStorageObject, error) {
+	path = strings.TrimPrefix(path, "/")
+	if bucket == "" {
+		bucket = path
+		path = ""
+		if i := strings.Index(bucket, "/"); i != -1 {
+			bucket, path = bucket[:i], bucket[i+1:]
+		}
+	}
+	return newStorageObject(providerType, bucket, path, region)
+}
+
+func newStorageObject(providerType ProviderType, bucket string, key string, region string) (GoStorageObject, error) {
+	if bucket == "" {
+		return GoStorageObject{}, errors.New("URL does not contain a bucket name")
+	}
+	return GoStorageObject{Bucket: bucket, Key: key, Region: region, ProviderType: providerType}, nil
+}
+
+// escapeKey escapes all segments of the key, but keeps the slashes between them
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
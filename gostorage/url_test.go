@@ -0,0 +1,111 @@
+package gostorage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	aws := func(bucket, key, region string) GoStorageObject {
+		return GoStorageObject{Bucket: bucket, Key: key, Region: region, ProviderType: ProviderAWS}
+	}
+	google := func(bucket, key string) GoStorageObject {
+		return GoStorageObject{Bucket: bucket, Key: key, ProviderType: ProviderGoogle}
+	}
+
+	tests := []struct {
+		url  string
+		want GoStorageObject
+	}{
+		{"s3://bucket/dir/file.png", aws("bucket", "dir/file.png", "")},
+		{"s3://bucket", aws("bucket", "", "")},
+		{"S3://bucket/file.png", aws("bucket", "file.png", "")},
+		{"gs://bucket/dir/file.png", google("bucket", "dir/file.png")},
+		{"gs://bucket/", google("bucket", "")},
+
+		{"https://bucket.s3.amazonaws.com/file.png", aws("bucket", "file.png", DefaultAWSRegion)},
+		{"https://bucket.s3.eu-central-1.amazonaws.com/dir/file.png", aws("bucket", "dir/file.png", "eu-central-1")},
+		{"https://bucket.s3-us-west-2.amazonaws.com/file.png", aws("bucket", "file.png", "us-west-2")},
+		{"https://bucket.s3-external-1.amazonaws.com/file.png", aws("bucket", "file.png", DefaultAWSRegion)},
+		{"https://my.dotted.bucket.s3.eu-west-1.amazonaws.com/file.png", aws("my.dotted.bucket", "file.png", "eu-west-1")},
+		{"https://bucket.s3.dualstack.us-west-2.amazonaws.com/file.png", aws("bucket", "file.png", "us-west-2")},
+		{"https://s3.amazonaws.com/bucket/file.png", aws("bucket", "file.png", DefaultAWSRegion)},
+		{"https://s3.eu-central-1.amazonaws.com/bucket/dir/file.png", aws("bucket", "dir/file.png", "eu-central-1")},
+		{"https://s3-us-west-2.amazonaws.com/bucket/file.png", aws("bucket", "file.png", "us-west-2")},
+		{"https://s3.dualstack.us-west-2.amazonaws.com/bucket/file.png", aws("bucket", "file.png", "us-west-2")},
+		{"https://BUCKET.S3.EU-WEST-1.AMAZONAWS.COM/file.png", aws("bucket", "file.png", "eu-west-1")},
+		{"https://bucket.s3.amazonaws.com/with%20space.png", aws("bucket", "with space.png", DefaultAWSRegion)},
+
+		{"https://storage.googleapis.com/bucket/dir/file.png", google("bucket", "dir/file.png")},
+		{"https://storage.cloud.google.com/bucket/file.png", google("bucket", "file.png")},
+		{"https://bucket.storage.googleapis.com/dir/file.png", google("bucket", "dir/file.png")},
+		{"https://storage.googleapis.com/storage/v1/b/bucket/o/file.png", google("bucket", "file.png")},
+		{"https://storage.googleapis.com/storage/v1/b/bucket", google("bucket", "")},
+		{"https://storage.googleapis.com/bucket/with%20space.png", google("bucket", "with space.png")},
+
+		{"dir/file.png", GoStorageObject{IsLocal: true, LocalFilePath: "dir/file.png"}},
+		{"/tmp/file.png", GoStorageObject{IsLocal: true, LocalFilePath: "/tmp/file.png"}},
+		{"file:///tmp/file.png", GoStorageObject{IsLocal: true, LocalFilePath: "/tmp/file.png"}},
+	}
+	for _, test := range tests {
+		got, err := ParseURL(test.url)
+		if err != nil {
+			t.Errorf("ParseURL(%v) returned error %v", test.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseURL(%v) = %+v, expected %+v", test.url, got, test.want)
+		}
+	}
+}
+
+func TestParseURLErrors(t *testing.T) {
+	urls := []string{
+		"ftp://bucket/file.png",
+		"s3:///file.png",
+		"gs://",
+		"https://example.com/bucket/file.png",
+		"https://ec2.us-east-1.amazonaws.com/bucket/file.png",
+		"https://storage.googleapis.com/",
+		"https://bucket.s3.amazonaws.com:port/file.png",
+	}
+	for _, url := range urls {
+		if got, err := ParseURL(url); err == nil {
+			t.Errorf("ParseURL(%v) = %+v, expected an error", url, got)
+		}
+	}
+}
+
+func TestURLRoundTrip(t *testing.T) {
+	objects := []GoStorageObject{
+		{Bucket: "bucket", Key: "dir/file.png", Region: "eu-central-1", ProviderType: ProviderAWS},
+		{Bucket: "bucket", Key: "with space/ä+%.png", Region: DefaultAWSRegion, ProviderType: ProviderAWS},
+		{Bucket: "my.dotted.bucket", Key: "file.png", Region: "us-west-2", ProviderType: ProviderAWS},
+		{Bucket: "bucket", Key: "", Region: "eu-west-1", ProviderType: ProviderAWS},
+		{Bucket: "bucket", Key: "dir/file.png", ProviderType: ProviderGoogle},
+		{Bucket: "bucket", Key: "with space/ä+%.png", ProviderType: ProviderGoogle},
+		{Bucket: "bucket", Key: "", ProviderType: ProviderGoogle},
+	}
+	for _, object := range objects {
+		for _, style := range []URLStyle{URLStyleNative, URLStyleVirtualHosted, URLStylePath} {
+			want := object
+			if style == URLStyleNative {
+				// native URLs do not carry a region
+				want.Region = ""
+			}
+			got, err := ParseURL(object.URL(style))
+			if err != nil {
+				t.Errorf("ParseURL(%v) returned error %v", object.URL(style), err)
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseURL(%v) = %+v, expected %+v", object.URL(style), got, want)
+			}
+		}
+	}
+
+	local := GoStorageObject{IsLocal: true, LocalFilePath: "/tmp/file.png"}
+	if got, err := ParseURL(local.URL(URLStyleNative)); err != nil || !reflect.DeepEqual(got, local) {
+		t.Errorf("ParseURL(%v) = %+v, %v, expected %+v", local.URL(URLStyleNative), got, err, local)
+	}
+}
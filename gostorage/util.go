@@ -1,27 +1,122 @@
 package gostorage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
+	"runtime"
+	"strconv"
+	"sync"
 
+	"cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 )
 
 func checkErr(err interface{}, msg interface{}) {
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", msg)
-		os.Exit(1)
+		fail(err, msg)
 	}
 }
 
+// capturingGoroutines holds the number of running catchErrors calls by goroutine id
+var capturingGoroutines sync.Map
+
+// storageError is the error returned by catchErrors, it wraps the error which caused the failure if there is one
+type storageError struct {
+	msg string
+	err error
+}
+
+func (e *storageError) Error() string { return e.msg }
+func (e *storageError) Unwrap() error { return e.err }
+
+// fail prints msg and terminates the process, within catchErrors of the same goroutine the error is returned by
+// catchErrors instead
+func fail(err interface{}, msg interface{}) {
+	if _, capturing := capturingGoroutines.Load(goroutineID()); capturing {
+		cause, _ := err.(error)
+		panic(&storageError{msg: fmt.Sprint(msg), err: cause})
+	}
+	fmt.Fprintln(os.Stderr, "Error:", msg)
+	os.Exit(1)
+}
+
+// failWith passes on an error returned by catchErrors
+func failWith(err error) {
+	fail(errors.Unwrap(err), err.Error())
+}
+
+// catchErrors runs f and returns the error which would otherwise have terminated the process. Only failures of the
+// calling goroutine are captured, goroutines started by f still terminate the process.
+func catchErrors(f func()) (err error) {
+	id := goroutineID()
+	depth, _ := capturingGoroutines.Load(id)
+	depthValue, _ := depth.(int)
+	capturingGoroutines.Store(id, depthValue+1)
+	defer func() {
+		if depthValue == 0 {
+			capturingGoroutines.Delete(id)
+		} else {
+			capturingGoroutines.Store(id, depthValue)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			storageErr, ok := r.(*storageError)
+			if !ok {
+				panic(r)
+			}
+			err = storageErr
+		}
+	}()
+	f()
+	return nil
+}
+
+// goroutineID returns the id of the calling goroutine from the header of its stack trace ("goroutine 42 [running]:")
+func goroutineID() uint64 {
+	buffer := make([]byte, 64)
+	buffer = bytes.TrimPrefix(buffer[:runtime.Stack(buffer, false)], []byte("goroutine "))
+	if i := bytes.IndexByte(buffer, ' '); i != -1 {
+		buffer = buffer[:i]
+	}
+	id, _ := strconv.ParseUint(string(buffer), 10, 64)
+	return id
+}
+
+// hasAWSErrorCode checks whether err is an AWS API error with the given error code
+func hasAWSErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// httpStatusCode returns the HTTP status code of an AWS or Google API error, or 0 for other errors
+func httpStatusCode(err error) int {
+	var responseError *awshttp.ResponseError
+	if errors.As(err, &responseError) {
+		return responseError.HTTPStatusCode()
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return http.StatusNotFound
+	}
+	return 0
+}
+
 func readFile(fileLocation string) []byte {
 	file, err := ioutil.ReadFile(fileLocation)
 	checkErr(err, fmt.Sprintf("unable to read file content from %v, Error: %v", fileLocation, err))
@@ -52,95 +147,41 @@ func LoadCredentialsFromDefaultLocation() (*aws.Credentials, *google.Credentials
 		checkErr(err, err)
 	}
 
-	//Set type for all configuration files to .yaml
-	viper.AddConfigPath(wd)
-	viper.SetConfigType("yaml")
-	viper.SetConfigName("gcp-credentials")
-	err = viper.MergeInConfig()
+	//Set type for all configuration files to .yaml, a separate viper instance is used so multiple configurations don't interfere
+	credentialsConfig := viper.New()
+	credentialsConfig.AddConfigPath(wd)
+	credentialsConfig.SetConfigType("yaml")
+	credentialsConfig.SetConfigName("gcp-credentials")
+	err = credentialsConfig.MergeInConfig()
 	checkErr(err, fmt.Sprintf("unable to find credentials file {%v}, Error: %v", "gcp-credentials", err))
 
-	viper.SetConfigName("aws-credentials")
-	err = viper.MergeInConfig()
+	credentialsConfig.SetConfigName("aws-credentials")
+	err = credentialsConfig.MergeInConfig()
 	checkErr(err, fmt.Sprintf("unable to find credentials file {%v}, Error: %v", "aws-credentials", err))
 
 	googleCredentials, err := google.CredentialsFromJSON(
 		context.Background(),
 		readFile(path.Join(wd, "gcp-credentials.yaml")),
-		"https://www.googleapis.com/auth/devstorage.full_control",
+		GoogleStorageScope,
 	)
 	checkErr(err, err)
 
 	awsCredentials := &aws.Credentials{
-		AccessKeyID:     viper.GetString(AWSAccessKey),
-		SecretAccessKey: viper.GetString(AWSSecretAccessKey),
-		SessionToken:    viper.GetString(AWSSessionTokenKey),
+		AccessKeyID:     credentialsConfig.GetString(AWSAccessKey),
+		SecretAccessKey: credentialsConfig.GetString(AWSSecretAccessKey),
+		SessionToken:    credentialsConfig.GetString(AWSSessionTokenKey),
 	}
 	return awsCredentials, googleCredentials
 }
 
 // parseUrlToGoStorageObject parses Object/Bucket URLs from AWS and Google to extract information such as bucketName, key, region etc.
 func parseUrlToGoStorageObject(urlString string) GoStorageObject {
-	if isAWSUrl(urlString) {
-		return parseAWSUrl(urlString)
-	} else if isGoogleUrl(urlString) {
-		return parseGoogleUrl(urlString)
-	} else {
-		if _, err := os.Stat(urlString); errors.Is(err, os.ErrNotExist) {
+	storageObject, err := ParseURL(urlString)
+	checkErr(err, fmt.Sprintf("unable to parse storage URL {%v}, Error: %v", urlString, err))
+	if storageObject.IsLocal {
+		if _, err := os.Stat(storageObject.LocalFilePath); errors.Is(err, os.ErrNotExist) {
 			checkErr(err, fmt.Sprintf("unable to find local file from {%v}, Error: %v", urlString, err))
 		}
-		return GoStorageObject{IsLocal: true, LocalFilePath: urlString}
-	}
-}
-
-// parseAWSUrl AWS Object URL (with explicit region)
-func parseAWSUrl(urlString string) GoStorageObject {
-	var bucket string
-	var key string
-	var region string
-
-	urlString = urlString[strings.Index(urlString, "https://")+len("https://"):]
-	bucket = urlString[:strings.Index(urlString, ".")]
-	urlString = urlString[strings.Index(urlString, ".")+len(".s3."):]
-	if strings.HasPrefix(urlString, "amazonaws.com") { //No region specified
-		region = DefaultAWSRegion
-	} else {
-		region = urlString[:strings.Index(urlString, ".")]
-		urlString = urlString[strings.Index(urlString, ".")+1:]
 	}
-	urlString = urlString[strings.Index(urlString, "amazonaws.com")+len("amazonaws.com"):]
-	if strings.HasPrefix(urlString, "/") {
-		urlString = urlString[1:]
-	}
-	key = urlString
-	return GoStorageObject{Bucket: bucket, Key: key, Region: region, ProviderType: ProviderAWS}
-}
-
-// parseGoogleUrl Google Object URL
-func parseGoogleUrl(urlString string) GoStorageObject {
-	var bucket string
-	var key string
-
-	if strings.HasPrefix(urlString, "gs://") {
-		urlString = urlString[strings.Index(urlString, "gs://")+len("gs://"):]
-	} else if strings.HasPrefix(urlString, "https://storage.cloud.google.com/") {
-		urlString = urlString[strings.Index(urlString, "https://storage.cloud.google.com/")+len("https://storage.cloud.google.com/"):]
-	}
-	if strings.Contains(urlString, "/") {
-		bucket = urlString[:strings.Index(urlString, "/")]
-		key = urlString[strings.Index(urlString, "/")+1:]
-	} else {
-		bucket = urlString
-	}
-	return GoStorageObject{Bucket: bucket, Key: key, ProviderType: ProviderGoogle}
-}
-
-// isGoogleUrl Google Object URL: gs://gostorage-bucket-test/test.png
-// Google Object URL: https://storage.cloud.google.com/gostorage-bucket-test/test.png
-func isGoogleUrl(urlString string) bool {
-	return strings.HasPrefix(urlString, "gs://") || strings.Contains(urlString, "storage.cloud.google.com")
-}
-
-// isAWSUrl AWS Object URL: https://gostorage-bucket-test.s3.amazonaws.com/newfile.png
-func isAWSUrl(urlString string) bool {
-	return strings.HasPrefix(urlString, "https://") && strings.Contains(urlString, "s3")
+	return storageObject
 }
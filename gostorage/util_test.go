@@ -0,0 +1,46 @@
+package gostorage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCatchErrors(t *testing.T) {
+	cause := errors.New("cause")
+	err := catchErrors(func() { checkErr(cause, "operation failed") })
+	if err == nil || err.Error() != "operation failed" || !errors.Is(err, cause) {
+		t.Fatalf("catchErrors returned %v, expected the failure wrapping the cause", err)
+	}
+	if err = catchErrors(func() {}); err != nil {
+		t.Fatalf("catchErrors returned %v for a successful call", err)
+	}
+}
+
+func TestCatchErrorsNested(t *testing.T) {
+	err := catchErrors(func() {
+		if inner := catchErrors(func() { fail(nil, "inner") }); inner == nil {
+			t.Error("inner catchErrors did not return the failure")
+		}
+		// the outer call still captures after the inner one returned
+		fail(nil, "outer")
+	})
+	if err == nil || err.Error() != "outer" {
+		t.Fatalf("catchErrors returned %v, expected the outer failure", err)
+	}
+	if _, capturing := capturingGoroutines.Load(goroutineID()); capturing {
+		t.Error("goroutine is still capturing after catchErrors returned")
+	}
+}
+
+func TestCatchErrorsIsScopedToGoroutine(t *testing.T) {
+	capturedElsewhere := make(chan bool)
+	_ = catchErrors(func() {
+		go func() {
+			_, capturing := capturingGoroutines.Load(goroutineID())
+			capturedElsewhere <- capturing
+		}()
+		if <-capturedElsewhere {
+			t.Error("failures of another goroutine are captured")
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package gostorage
+
+import (
+	"fmt"
+	"time"
+)
+
+// ObjectVersion describes a single version of a storage object.
+// VersionId holds the S3 version ID or the Google Storage generation number respectively.
+type ObjectVersion struct {
+	Key            string
+	VersionId      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	Size           int64
+	LastModified   time.Time
+}
+
+func (s GoStorage) EnableVersioning(bucket GoStorageObject) {
+	s, span := s.startSpan("EnableVersioning", bucket)
+	defer span.End()
+	s.provider(bucket).setBucketVersioning(bucket, true)
+}
+
+func (s GoStorage) DisableVersioning(bucket GoStorageObject) {
+	s, span := s.startSpan("DisableVersioning", bucket)
+	defer span.End()
+	s.provider(bucket).setBucketVersioning(bucket, false)
+}
+
+// ListObjectVersions lists all versions of source.Key or, if no key is set, of all objects in source.Bucket
+func (s GoStorage) ListObjectVersions(source GoStorageObject) []ObjectVersion {
+	s, span := s.startSpan("ListObjectVersions", source)
+	defer span.End()
+	return s.provider(source).listObjectVersions(source)
+}
+
+// RestoreObjectVersion makes the version referenced by source.VersionId the current version of the object
+func (s GoStorage) RestoreObjectVersion(source GoStorageObject) {
+	s, span := s.startSpan("RestoreObjectVersion", source)
+	defer span.End()
+	if source.VersionId == "" {
+		fail(nil, fmt.Sprintf("unable to restore object %v, no version id specified", source.Key))
+	}
+	s.provider(source).restoreObjectVersion(source)
+}
+
+// filterVersionsByKey removes versions of objects which only share the prefix with key
+func filterVersionsByKey(versions []ObjectVersion, key string) []ObjectVersion {
+	var filtered []ObjectVersion
+	for _, v := range versions {
+		if v.Key == key {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
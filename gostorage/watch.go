@@ -0,0 +1,174 @@
+package gostorage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+type WatchEventType string
+
+const (
+	WatchEventCreated WatchEventType = "created"
+	WatchEventUpdated WatchEventType = "updated"
+	WatchEventDeleted WatchEventType = "deleted"
+	WatchEventError   WatchEventType = "error"
+)
+
+// WatchEvent reports a change of an object, Info is empty for deleted objects. Failed polls are reported with
+// WatchEventError and Err, the watch continues with the next poll.
+type WatchEvent struct {
+	Type   WatchEventType
+	Object GoStorageObject
+	Info   ObjectInfo
+	Err    error
+}
+
+type WatchOptions struct {
+	// StateFile persists the last seen state of the prefix, so a restarted watch only reports the changes since then.
+	// Without state file all existing objects are reported as created by the first poll. A state file can only be used
+	// for the bucket and prefix it was created for.
+	StateFile string
+}
+
+// Watch is like WatchWithOptions but does not persist its state
+func (s GoStorage) Watch(prefix GoStorageObject, interval time.Duration) <-chan WatchEvent {
+	return s.WatchWithOptions(prefix, interval, WatchOptions{})
+}
+
+// WatchWithOptions polls the objects whose key starts with prefix.Key every interval and reports created, updated
+// (changed ETag or generation) and deleted objects. The watch stops and the channel is closed when the context of s
+// (see WithContext) is done. The state is persisted after the events of a poll have been received, failed polls are
+// reported as WatchEventError and retried after interval.
+func (s GoStorage) WatchWithOptions(prefix GoStorageObject, interval time.Duration, options WatchOptions) <-chan WatchEvent {
+	events := make(chan WatchEvent)
+	snapshot := loadWatchState(options.StateFile, prefix)
+
+	go func() {
+		defer close(events)
+		ctx := s.context()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		// send returns false if the watch was stopped while waiting for the receiver
+		send := func(event WatchEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			var objects []ObjectInfo
+			if err := catchErrors(func() { objects = s.provider(prefix).listObjects(prefix) }); err != nil {
+				if !send(WatchEvent{Type: WatchEventError, Object: prefix, Err: err}) {
+					return
+				}
+			} else {
+				current := map[string]ObjectInfo{}
+				for _, info := range objects {
+					current[info.Key] = info
+				}
+				for _, event := range diffWatchSnapshot(prefix, snapshot, current) {
+					if !send(event) {
+						return
+					}
+				}
+				snapshot = map[string]string{}
+				for key, info := range current {
+					snapshot[key] = watchFingerprint(info)
+				}
+				if err := catchErrors(func() { saveWatchState(options.StateFile, prefix, snapshot) }); err != nil {
+					if !send(WatchEvent{Type: WatchEventError, Object: prefix, Err: err}) {
+						return
+					}
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// diffWatchSnapshot returns the events (sorted by key) between the fingerprints of the previous poll and the current objects
+func diffWatchSnapshot(prefix GoStorageObject, previous map[string]string, current map[string]ObjectInfo) []WatchEvent {
+	var events []WatchEvent
+	for key, info := range current {
+		object := prefix
+		object.Key = key
+		fingerprint, ok := previous[key]
+		if !ok {
+			events = append(events, WatchEvent{Type: WatchEventCreated, Object: object, Info: info})
+		} else if fingerprint != watchFingerprint(info) {
+			events = append(events, WatchEvent{Type: WatchEventUpdated, Object: object, Info: info})
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			object := prefix
+			object.Key = key
+			events = append(events, WatchEvent{Type: WatchEventDeleted, Object: object})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Object.Key < events[j].Object.Key
+	})
+	return events
+}
+
+// watchFingerprint identifies the content of an object by ETag and generation (Google)
+func watchFingerprint(info ObjectInfo) string {
+	return info.ETag + "/" + info.VersionId
+}
+
+// watchState is the content of a state file, the objects map keys to their fingerprints
+type watchState struct {
+	ProviderType ProviderType
+	Bucket       string
+	Prefix       string
+	Objects      map[string]string
+}
+
+// loadWatchState returns the fingerprints of the state file, the state file must belong to prefix
+func loadWatchState(stateFile string, prefix GoStorageObject) map[string]string {
+	if stateFile == "" {
+		return map[string]string{}
+	}
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}
+	}
+	checkErr(err, fmt.Sprintf("unable to read watch state %v, Error: %v", stateFile, err))
+	state := watchState{}
+	err = json.Unmarshal(data, &state)
+	checkErr(err, fmt.Sprintf("unable to parse watch state %v, Error: %v", stateFile, err))
+	if state.ProviderType != prefix.ProviderType || state.Bucket != prefix.Bucket || state.Prefix != prefix.Key {
+		fail(nil, fmt.Sprintf("watch state %v belongs to %v %v/%v, not to %v %v/%v", stateFile, state.ProviderType,
+			state.Bucket, state.Prefix, prefix.ProviderType, prefix.Bucket, prefix.Key))
+	}
+	if state.Objects == nil {
+		state.Objects = map[string]string{}
+	}
+	return state.Objects
+}
+
+func saveWatchState(stateFile string, prefix GoStorageObject, snapshot map[string]string) {
+	if stateFile == "" {
+		return
+	}
+	data, err := json.Marshal(watchState{ProviderType: prefix.ProviderType, Bucket: prefix.Bucket, Prefix: prefix.Key, Objects: snapshot})
+	checkErr(err, fmt.Sprintf("unable to write watch state %v, Error: %v", stateFile, err))
+	err = os.WriteFile(stateFile+".tmp", data, 0600)
+	if err == nil {
+		err = os.Rename(stateFile+".tmp", stateFile)
+	}
+	checkErr(err, fmt.Sprintf("unable to write watch state %v, Error: %v", stateFile, err))
+}